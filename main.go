@@ -9,8 +9,14 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/jojohappy/luxun/pkg/controller"
+	"github.com/jojohappy/luxun/pkg/deadletter"
+	_ "github.com/jojohappy/luxun/pkg/handler/elasticsearch"
+	_ "github.com/jojohappy/luxun/pkg/handler/file"
+	_ "github.com/jojohappy/luxun/pkg/handler/kafka"
+	_ "github.com/jojohappy/luxun/pkg/handler/webhook"
 	luxunhttp "github.com/jojohappy/luxun/pkg/http"
 	"github.com/jojohappy/luxun/pkg/stream"
 )
@@ -18,10 +24,16 @@ import (
 var listenIp = flag.String("listen_ip", "", "IP to listen on, defaults all")
 var listenPort = flag.Int("port", 9280, "listen port")
 var prometheusEndpoint = flag.String("prometheus_endpoint", "/metrics", "Endpoint to expose Prometheus metrics on")
+var shutdownTimeout = flag.Duration("shutdown_timeout", 30*time.Second, "max time to drain the stream and flush sinks on shutdown")
 
 func main() {
 	flag.Parse()
-	stream.Init()
+	if err := deadletter.Init(); nil != err {
+		log.Fatal(err)
+	}
+	if err := stream.Init(); nil != err {
+		log.Fatal(err)
+	}
 	mux := http.NewServeMux()
 	luxunhttp.RegisterHandler(mux, *prometheusEndpoint)
 
@@ -31,12 +43,25 @@ func main() {
 		Addr:    fmt.Sprintf("%s:%d", *listenIp, *listenPort),
 		Handler: mux,
 	}
-	go log.Fatal(s.ListenAndServe())
+	go func() {
+		if err := s.ListenAndServe(); nil != err && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
 
 	sigterm := make(chan os.Signal, 1)
 	signal.Notify(sigterm, syscall.SIGTERM)
 	signal.Notify(sigterm, syscall.SIGINT)
 	<-sigterm
+
+	// stop informers first, then drain the stream into the sinks
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
 	controller.Stop()
+	if err := stream.Shutdown(ctx); nil != err {
+		fmt.Printf("failed to shut down stream gracefully: %s\n", err.Error())
+	} else {
+		fmt.Println("stream drained and sinks flushed")
+	}
 	s.Shutdown(context.Background())
 }
@@ -0,0 +1,422 @@
+package buffer
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+const (
+	segmentSuffix    = ".seg"
+	checkpointSuffix = ".checkpoint"
+)
+
+var (
+	bufferDir                = flag.String("buffer-dir", "", "directory of the write-ahead buffer between the stream and sinks, empty disables it")
+	bufferSegmentSize        = flag.Int64("buffer-segment-size", 64, "size in megabytes of a buffer segment file")
+	bufferMaxSize            = flag.Int64("buffer-max-size", 1024, "max size in megabytes of all buffer segments, 0 means no limit")
+	bufferCheckpointInterval = flag.Duration("buffer-checkpoint-interval", time.Second, "interval of persisting the read position of each sink")
+	bufferFsync              = flag.Bool("buffer-fsync", false, "fsync every event appended to the buffer")
+)
+
+// Position is the location of the next event to read in the buffer.
+type Position struct {
+	Segment int64 `json:"segment"`
+	Offset  int64 `json:"offset"`
+}
+
+// Buffer is a write-ahead log of events split into segment files. Every
+// sink reads it with its own Reader and checkpoint, segments are removed
+// once all readers have committed past them.
+type Buffer struct {
+	dir                string
+	segmentSize        int64
+	maxSize            int64
+	checkpointInterval time.Duration
+	fsync              bool
+
+	lock       sync.Mutex
+	segments   map[int64]int64
+	current    *os.File
+	currentSeq int64
+	notify     chan struct{}
+	readers    map[string]*Reader
+}
+
+// Init opens the buffer configured by flags, it returns nil if the buffer
+// is disabled.
+func Init() (*Buffer, error) {
+	if *bufferDir == "" {
+		return nil, nil
+	}
+	return Open(*bufferDir, *bufferSegmentSize<<20, *bufferMaxSize<<20, *bufferCheckpointInterval, *bufferFsync)
+}
+
+func Open(dir string, segmentSize, maxSize int64, checkpointInterval time.Duration, fsync bool) (*Buffer, error) {
+	if segmentSize <= 0 {
+		return nil, fmt.Errorf("buffer segment size must be positive")
+	}
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		return nil, err
+	}
+	b := &Buffer{
+		dir:                dir,
+		segmentSize:        segmentSize,
+		maxSize:            maxSize,
+		checkpointInterval: checkpointInterval,
+		fsync:              fsync,
+		segments:           make(map[int64]int64),
+		notify:             make(chan struct{}),
+		readers:            make(map[string]*Reader),
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if nil != err {
+		return nil, err
+	}
+	var last int64
+	for _, path := range paths {
+		seq, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(path), segmentSuffix), 10, 64)
+		if nil != err {
+			continue
+		}
+		info, err := os.Stat(path)
+		if nil != err {
+			return nil, err
+		}
+		b.segments[seq] = info.Size()
+		if seq > last {
+			last = seq
+		}
+	}
+
+	// always start a new segment, the last one may end with a partial line
+	if err := b.openSegment(last + 1); nil != err {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Buffer) segmentPath(seq int64) string {
+	return filepath.Join(b.dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
+
+func (b *Buffer) openSegment(seq int64) error {
+	f, err := os.OpenFile(b.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if nil != err {
+		return err
+	}
+	if nil != b.current {
+		b.current.Close()
+	}
+	b.current = f
+	b.currentSeq = seq
+	b.segments[seq] = 0
+	return nil
+}
+
+func (b *Buffer) size() int64 {
+	var size int64
+	for _, s := range b.segments {
+		size += s
+	}
+	return size
+}
+
+// nextSegment returns the first segment after seq, or 0 if there is none.
+func (b *Buffer) nextSegment(seq int64) int64 {
+	var next int64
+	for s := range b.segments {
+		if s > seq && (next == 0 || s < next) {
+			next = s
+		}
+	}
+	return next
+}
+
+func (b *Buffer) firstSegment() int64 {
+	return b.nextSegment(0)
+}
+
+func (b *Buffer) Append(ev *model.Event) error {
+	line, err := json.Marshal(ev)
+	if nil != err {
+		return err
+	}
+	line = append(line, '\n')
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.maxSize > 0 && b.size()+int64(len(line)) > b.maxSize {
+		return fmt.Errorf("buffer is full")
+	}
+	if b.segments[b.currentSeq] > 0 && b.segments[b.currentSeq]+int64(len(line)) > b.segmentSize {
+		if err := b.openSegment(b.currentSeq + 1); nil != err {
+			return err
+		}
+	}
+	n, err := b.current.Write(line)
+	b.segments[b.currentSeq] += int64(n)
+	if nil != err {
+		return err
+	}
+	if b.fsync {
+		if err := b.current.Sync(); nil != err {
+			return err
+		}
+	}
+	close(b.notify)
+	b.notify = make(chan struct{})
+	return nil
+}
+
+// Reader returns the reader of name, starting at its last checkpoint or at
+// the oldest segment if it has none.
+func (b *Buffer) Reader(name string) (*Reader, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if r, ok := b.readers[name]; ok {
+		return r, nil
+	}
+
+	r := &Reader{
+		b:    b,
+		name: name,
+		path: filepath.Join(b.dir, name+checkpointSuffix),
+	}
+	content, err := ioutil.ReadFile(r.path)
+	if nil != err && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if nil == err {
+		if err := json.Unmarshal(content, &r.pos); nil != err {
+			return nil, fmt.Errorf("invalid checkpoint of %s: %v", name, err)
+		}
+	}
+	if first := b.firstSegment(); r.pos.Segment < first {
+		r.pos = Position{Segment: first}
+	}
+	r.committed = r.pos
+	r.committedSeq = r.pos.Segment
+	b.readers[name] = r
+	return r, nil
+}
+
+// gc removes segments which all readers have committed past.
+func (b *Buffer) gc() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.readers) == 0 {
+		return
+	}
+	var min int64 = -1
+	for _, r := range b.readers {
+		seq := atomic.LoadInt64(&r.committedSeq)
+		if min == -1 || seq < min {
+			min = seq
+		}
+	}
+	for seq := range b.segments {
+		if seq < min && seq != b.currentSeq {
+			if err := os.Remove(b.segmentPath(seq)); nil != err && !os.IsNotExist(err) {
+				fmt.Printf("failed to remove buffer segment %d: %s\n", seq, err.Error())
+				continue
+			}
+			delete(b.segments, seq)
+		}
+	}
+}
+
+func (b *Buffer) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, r := range b.readers {
+		r.close()
+	}
+	if nil == b.current {
+		return nil
+	}
+	err := b.current.Close()
+	b.current = nil
+	return err
+}
+
+// Reader reads the buffer in order on behalf of one sink. Next must not be
+// called concurrently, Commit and Sync may be called from any goroutine.
+type Reader struct {
+	// accessed atomically by the buffer, keep it 64-bit aligned
+	committedSeq int64
+
+	b    *Buffer
+	name string
+	path string
+
+	pos     Position
+	file    *os.File
+	fileSeq int64
+	br      *bufio.Reader
+
+	lock      sync.Mutex
+	committed Position
+	lastSync  time.Time
+}
+
+// Next blocks until an event is available or stopCh is closed, in which
+// case a nil event is returned. The returned position is to be committed
+// once the event is delivered.
+func (r *Reader) Next(stopCh <-chan struct{}) (*model.Event, Position, error) {
+	for {
+		r.b.lock.Lock()
+		notify := r.b.notify
+		currentSeq := r.b.currentSeq
+		_, exists := r.b.segments[r.pos.Segment]
+		next := r.b.nextSegment(r.pos.Segment)
+		r.b.lock.Unlock()
+
+		if !exists {
+			if next == 0 {
+				select {
+				case <-notify:
+					continue
+				case <-stopCh:
+					return nil, r.pos, nil
+				}
+			}
+			r.moveTo(next)
+			continue
+		}
+
+		if nil == r.file || r.fileSeq != r.pos.Segment {
+			if err := r.open(); nil != err {
+				return nil, r.pos, err
+			}
+		}
+		line, err := r.br.ReadBytes('\n')
+		if nil == err {
+			r.pos.Offset += int64(len(line))
+			ev := &model.Event{}
+			if err := json.Unmarshal(line, ev); nil != err {
+				fmt.Printf("skipped corrupted event in buffer segment %d: %s\n", r.pos.Segment, err.Error())
+				continue
+			}
+			return ev, r.pos, nil
+		}
+		if err != io.EOF {
+			return nil, r.pos, err
+		}
+
+		if r.pos.Segment != currentSeq && next != 0 {
+			if len(line) > 0 {
+				fmt.Printf("skipped partial event at the end of buffer segment %d\n", r.pos.Segment)
+			}
+			r.moveTo(next)
+			continue
+		}
+
+		// caught up with the writer, read the last line again once it is complete
+		if _, err := r.file.Seek(r.pos.Offset, io.SeekStart); nil != err {
+			return nil, r.pos, err
+		}
+		r.br.Reset(r.file)
+		select {
+		case <-notify:
+		case <-stopCh:
+			return nil, r.pos, nil
+		}
+	}
+}
+
+func (r *Reader) open() error {
+	r.close()
+	f, err := os.Open(r.b.segmentPath(r.pos.Segment))
+	if nil != err {
+		return err
+	}
+	if _, err := f.Seek(r.pos.Offset, io.SeekStart); nil != err {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.fileSeq = r.pos.Segment
+	r.br = bufio.NewReader(f)
+	return nil
+}
+
+func (r *Reader) moveTo(seq int64) {
+	r.close()
+	r.pos = Position{Segment: seq}
+}
+
+func (r *Reader) close() {
+	if nil != r.file {
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+// Commit marks everything before pos as delivered. The checkpoint is
+// persisted at most once per checkpoint interval, so a crash may deliver
+// the events of the last interval again.
+func (r *Reader) Commit(pos Position) error {
+	r.lock.Lock()
+	r.committed = pos
+	atomic.StoreInt64(&r.committedSeq, pos.Segment)
+	if time.Since(r.lastSync) < r.b.checkpointInterval {
+		r.lock.Unlock()
+		return nil
+	}
+	err := r.sync()
+	r.lock.Unlock()
+	r.b.gc()
+	return err
+}
+
+// Sync persists the last committed position.
+func (r *Reader) Sync() error {
+	r.lock.Lock()
+	err := r.sync()
+	r.lock.Unlock()
+	r.b.gc()
+	return err
+}
+
+func (r *Reader) sync() error {
+	content, err := json.Marshal(r.committed)
+	if nil != err {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0644); nil != err {
+		return err
+	}
+	if err := os.Rename(tmp, r.path); nil != err {
+		return err
+	}
+	r.lastSync = time.Now()
+	return nil
+}
+
+// Segments returns the sequence numbers of the segments on disk.
+func (b *Buffer) Segments() []int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	segments := make([]int64, 0, len(b.segments))
+	for seq := range b.segments {
+		segments = append(segments, seq)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments
+}
@@ -0,0 +1,77 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestBufferResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "luxun-buffer")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := Open(dir, 256, 0, 0, false)
+	if nil != err {
+		t.Fatal(err)
+	}
+	r, err := b.Reader("es")
+	if nil != err {
+		t.Fatal(err)
+	}
+	names := []string{"a", "b", "c", "d"}
+	for _, name := range names {
+		if err := b.Append(&model.Event{Name: name}); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	stopCh := make(chan struct{})
+	for _, name := range names[:2] {
+		ev, pos, err := r.Next(stopCh)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if ev.Name != name {
+			t.Fatalf("excepted %s, got %s", name, ev.Name)
+		}
+		r.Commit(pos)
+	}
+	// read but not committed, it is delivered again after restart
+	if _, _, err := r.Next(stopCh); nil != err {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	b, err = Open(dir, 256, 0, 0, false)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	r, err = b.Reader("es")
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, name := range names[2:] {
+		ev, pos, err := r.Next(stopCh)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if ev.Name != name {
+			t.Fatalf("excepted %s, got %s", name, ev.Name)
+		}
+		r.Commit(pos)
+	}
+	if segments := b.Segments(); len(segments) != 2 {
+		t.Fatalf("excepted consumed segments to be removed, got %v", segments)
+	}
+
+	close(stopCh)
+	if ev, _, err := r.Next(stopCh); nil != ev || nil != err {
+		t.Fatalf("excepted nothing after stop, got %v, %v", ev, err)
+	}
+}
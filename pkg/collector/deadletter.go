@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jojohappy/luxun/pkg/deadletter"
+)
+
+var (
+	deadLetterDesc         = prometheus.NewDesc("luxun_dead_letter_events", "Number of events waiting in the dead letter queue.", []string{"sink"}, nil)
+	deadLetterRejectedDesc = prometheus.NewDesc("luxun_dead_letter_rejected_events", "Number of events rejected by the sink or out of replay attempts.", []string{"sink"}, nil)
+)
+
+type deadLetterCollector struct{}
+
+func NewDeadLetterCollector() *deadLetterCollector {
+	return &deadLetterCollector{}
+}
+
+func (d *deadLetterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- deadLetterDesc
+	ch <- deadLetterRejectedDesc
+}
+
+func (d *deadLetterCollector) Collect(ch chan<- prometheus.Metric) {
+	for sink, count := range deadletter.Counts() {
+		ch <- prometheus.MustNewConstMetric(deadLetterDesc, prometheus.GaugeValue, float64(count), sink)
+	}
+	for sink, count := range deadletter.RejectedCounts() {
+		ch <- prometheus.MustNewConstMetric(deadLetterRejectedDesc, prometheus.GaugeValue, float64(count), sink)
+	}
+}
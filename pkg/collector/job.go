@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jojohappy/luxun/pkg/storage"
+)
+
+var (
+	jobFinishedDesc      = prometheus.NewDesc("luxun_job_finished_total", "Number of jobs finished, by cron job of the jobs, the job label is empty for jobs not created by a cron job.", []string{"namespace", "job", "status"}, nil)
+	jobDurationDesc      = prometheus.NewDesc("luxun_job_last_duration_seconds", "Duration of the last finished job, by cron job of the jobs, the job label is empty for jobs not created by a cron job.", []string{"namespace", "job"}, nil)
+	cronJobMissedDesc    = prometheus.NewDesc("luxun_cronjob_missed_schedules_total", "Number of schedules of a cron job which were not run in time.", []string{"namespace", "cronjob"}, nil)
+	cronJobSuspendedDesc = prometheus.NewDesc("luxun_cronjob_suspended", "Whether a cron job is suspended.", []string{"namespace", "cronjob"}, nil)
+)
+
+type jobCollector struct{}
+
+func NewJobCollector() *jobCollector {
+	return &jobCollector{}
+}
+
+func (j *jobCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jobFinishedDesc
+	ch <- jobDurationDesc
+	ch <- cronJobMissedDesc
+	ch <- cronJobSuspendedDesc
+}
+
+func (j *jobCollector) Collect(ch chan<- prometheus.Metric) {
+	s := storage.JobStorageInst()
+	for r, count := range s.Results() {
+		ch <- prometheus.MustNewConstMetric(jobFinishedDesc, prometheus.CounterValue, count, r.Namespace, r.Name, r.Status)
+	}
+	for k, duration := range s.Durations() {
+		ch <- prometheus.MustNewConstMetric(jobDurationDesc, prometheus.GaugeValue, duration, k.Namespace, k.Name)
+	}
+	for k, count := range s.Missed() {
+		ch <- prometheus.MustNewConstMetric(cronJobMissedDesc, prometheus.CounterValue, count, k.Namespace, k.Name)
+	}
+	for k, suspended := range s.Suspended() {
+		v := 0.0
+		if suspended {
+			v = 1
+		}
+		ch <- prometheus.MustNewConstMetric(cronJobSuspendedDesc, prometheus.GaugeValue, v, k.Namespace, k.Name)
+	}
+}
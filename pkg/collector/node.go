@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jojohappy/luxun/pkg/storage"
+)
+
+var (
+	nodeConditionDesc     = prometheus.NewDesc("luxun_node_status_condition", "The condition of a node.", []string{"node", "condition", "status"}, nil)
+	nodeUnschedulableDesc = prometheus.NewDesc("luxun_node_spec_unschedulable", "Whether a node is cordoned.", []string{"node"}, nil)
+	nodeTaintsDesc        = prometheus.NewDesc("luxun_node_spec_taints", "Number of taints of a node.", []string{"node"}, nil)
+	nodeTransitionsDesc   = prometheus.NewDesc("luxun_node_condition_transitions_total", "Number of transitions of node conditions to a status.", []string{"condition", "status"}, nil)
+)
+
+type nodeCollector struct{}
+
+func NewNodeCollector() *nodeCollector {
+	return &nodeCollector{}
+}
+
+func (n *nodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nodeConditionDesc
+	ch <- nodeUnschedulableDesc
+	ch <- nodeTaintsDesc
+	ch <- nodeTransitionsDesc
+}
+
+func (n *nodeCollector) Collect(ch chan<- prometheus.Metric) {
+	for node, status := range storage.NodeStorageInst().GetAll() {
+		for condition, s := range status.Conditions {
+			ch <- prometheus.MustNewConstMetric(nodeConditionDesc, prometheus.GaugeValue, 1, node, condition, s)
+		}
+		unschedulable := 0.0
+		if status.Unschedulable {
+			unschedulable = 1
+		}
+		ch <- prometheus.MustNewConstMetric(nodeUnschedulableDesc, prometheus.GaugeValue, unschedulable, node)
+		ch <- prometheus.MustNewConstMetric(nodeTaintsDesc, prometheus.GaugeValue, float64(status.Taints), node)
+	}
+	for t, count := range storage.NodeStorageInst().Transitions() {
+		ch <- prometheus.MustNewConstMetric(nodeTransitionsDesc, prometheus.CounterValue, count, t.Condition, t.Status)
+	}
+}
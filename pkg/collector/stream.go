@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jojohappy/luxun/pkg/stream"
+)
+
+var (
+	streamQueueDepthDesc    = prometheus.NewDesc("luxun_stream_queue_depth", "Number of events waiting in the input queue of the stream.", nil, nil)
+	streamQueueCapacityDesc = prometheus.NewDesc("luxun_stream_queue_capacity", "Capacity of the input queue of the stream.", nil, nil)
+	streamDroppedDesc       = prometheus.NewDesc("luxun_stream_dropped_events_total", "Number of events dropped because the input queue of the stream was full.", nil, nil)
+	streamSuppressedDesc    = prometheus.NewDesc("luxun_stream_suppressed_events_total", "Number of events suppressed by rate limiting or sampling.", []string{"cause", "key_type", "key"}, nil)
+	streamRedactionsDesc    = prometheus.NewDesc("luxun_stream_redactions_total", "Number of redactions applied to events.", []string{"rule"}, nil)
+)
+
+type streamCollector struct{}
+
+func NewStreamCollector() *streamCollector {
+	return &streamCollector{}
+}
+
+func (s *streamCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- streamQueueDepthDesc
+	ch <- streamQueueCapacityDesc
+	ch <- streamDroppedDesc
+	ch <- streamSuppressedDesc
+	ch <- streamRedactionsDesc
+}
+
+func (s *streamCollector) Collect(ch chan<- prometheus.Metric) {
+	depth, capacity, dropped := stream.Stats()
+	ch <- prometheus.MustNewConstMetric(streamQueueDepthDesc, prometheus.GaugeValue, float64(depth))
+	ch <- prometheus.MustNewConstMetric(streamQueueCapacityDesc, prometheus.GaugeValue, float64(capacity))
+	ch <- prometheus.MustNewConstMetric(streamDroppedDesc, prometheus.CounterValue, float64(dropped))
+	for key, count := range stream.SuppressedStats() {
+		ch <- prometheus.MustNewConstMetric(streamSuppressedDesc, prometheus.CounterValue, float64(count), key.Cause, key.KeyType, key.Key)
+	}
+	for rule, count := range stream.RedactionStats() {
+		ch <- prometheus.MustNewConstMetric(streamRedactionsDesc, prometheus.CounterValue, float64(count), rule)
+	}
+}
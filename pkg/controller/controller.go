@@ -3,7 +3,13 @@ package controller
 import (
 	"flag"
 	"fmt"
+	"strings"
+	"sync"
 
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -13,38 +19,171 @@ import (
 const DefaultResyncPeriod = 0
 const MaxRetries = 5
 
-var controllerBuilders = make(map[string]ControllerBuilder)
+const eventsController = "events"
+
+var (
+	namespaces         = flag.String("namespaces", "", "comma separated namespaces to watch, defaults all")
+	labelSelector      = flag.String("label-selector", "", "label selector of the objects watched by all controllers but events, which have no labels of their objects")
+	enabledControllers = flag.String("controllers", "", "comma separated controllers to run, defaults all")
+)
+
+var controllerBuilders = make(map[string]*controllerEntry)
 var controllStopCh = make(map[string]chan struct{})
+var controllWg sync.WaitGroup
 
-type ControllerBuilder func(client kubernetes.Interface) cache.Controller
+// ControllerBuilder builds a controller on the informers of f, which are
+// restricted to the namespace and selectors the controller is run with.
+type ControllerBuilder func(client kubernetes.Interface, f informers.SharedInformerFactory) cache.Controller
 
+type controllerEntry struct {
+	builder       ControllerBuilder
+	clusterScoped bool
+	labelSelector *string
+	fieldSelector *string
+}
+
+// RegisterController registers a controller of namespaced objects, it is
+// run once per namespace if namespaces are given. The flags
+// <name>-label-selector and <name>-field-selector restrict its watch, e.g.
+// --events-field-selector=type=Warning.
 func RegisterController(name string, fn ControllerBuilder) {
-	controllerBuilders[name] = fn
+	register(name, fn, false)
+}
+
+// RegisterClusterController registers a controller of cluster scoped
+// objects, which is run once. With namespaces it is only run if it is
+// listed in controllers.
+func RegisterClusterController(name string, fn ControllerBuilder) {
+	register(name, fn, true)
+}
+
+func register(name string, fn ControllerBuilder, clusterScoped bool) {
+	controllerBuilders[name] = &controllerEntry{
+		builder:       fn,
+		clusterScoped: clusterScoped,
+		labelSelector: flag.String(name+"-label-selector", "", "label selector of the objects watched by the "+name+" controller, overrides label-selector"),
+		fieldSelector: flag.String(name+"-field-selector", "", "field selector of the objects watched by the "+name+" controller"),
+	}
 }
 
 func Execute() {
-	client, err := initKubeClient()
+	client, config, err := initKubeClient()
+	if nil != err {
+		panic(err.Error())
+	}
+	nsList := splitList(*namespaces)
+	enabled := splitList(*enabledControllers)
+	for name, entry := range controllerBuilders {
+		if len(enabled) > 0 && !contains(enabled, name) {
+			continue
+		}
+		// objects out of any namespace are only watched with namespaces if
+		// they are asked for
+		if entry.clusterScoped && len(nsList) > 0 && !contains(enabled, name) {
+			fmt.Printf("skipping controller %s of cluster scoped objects, add it to controllers to watch them\n", name)
+			continue
+		}
+		ls := labelSelectorOf(name, *labelSelector, *entry.labelSelector)
+		if name == eventsController && *labelSelector != "" && ls == "" {
+			fmt.Printf("label-selector is not applied to events, use %s-label-selector\n", name)
+		}
+		tweak, err := listOptionsFunc(ls, *entry.fieldSelector)
+		if nil != err {
+			panic(fmt.Sprintf("invalid selector of controller %s: %s", name, err.Error()))
+		}
+		if entry.clusterScoped || len(nsList) == 0 {
+			fmt.Println("starting init controller: ", name)
+			f := informers.NewFilteredSharedInformerFactory(client, DefaultResyncPeriod, meta_v1.NamespaceAll, tweak)
+			start(name, entry.builder(client, f))
+			continue
+		}
+		for _, ns := range nsList {
+			fmt.Println("starting init controller: ", name+"/"+ns)
+			f := informers.NewFilteredSharedInformerFactory(client, DefaultResyncPeriod, ns, tweak)
+			start(name+"/"+ns, entry.builder(client, f))
+		}
+	}
+
+	dynamicControllers, err := newDynamicControllers(config, *dynamicWatches, nsList, *labelSelector)
 	if nil != err {
 		panic(err.Error())
 	}
-	for name, builder := range controllerBuilders {
+	for name, c := range dynamicControllers {
 		fmt.Println("starting init controller: ", name)
-		c := builder(client)
-		stopChC := make(chan struct{})
-		go c.Run(stopChC)
-		controllStopCh[name] = stopChC
-		fmt.Printf("controller %s started!\n", name)
+		start(name, c)
 	}
 }
 
+func start(name string, c cache.Controller) {
+	stopChC := make(chan struct{})
+	controllWg.Add(1)
+	go func() {
+		defer controllWg.Done()
+		c.Run(stopChC)
+	}()
+	controllStopCh[name] = stopChC
+	fmt.Printf("controller %s started!\n", name)
+}
+
+// Stop stops the informers of all controllers and waits for them to exit.
 func Stop() {
-	for name, stopChC := range controllStopCh {
+	for _, stopChC := range controllStopCh {
 		close(stopChC)
+	}
+	controllWg.Wait()
+	for name := range controllStopCh {
 		fmt.Printf("controller %s stopped!\n", name)
 	}
 }
 
-func initKubeClient() (kubernetes.Interface, error) {
+// labelSelectorOf returns the label selector of a controller, its own one
+// or the global one. Events don't have the labels of their objects, so the
+// global one would filter out all of them and is not applied to events.
+func labelSelectorOf(name, global, own string) string {
+	switch {
+	case own != "":
+		return own
+	case name == eventsController:
+		return ""
+	}
+	return global
+}
+
+// listOptionsFunc validates the selectors and returns the function
+// applying them to the list and watch requests of informers.
+func listOptionsFunc(labelSelector, fieldSelector string) (func(options *meta_v1.ListOptions), error) {
+	if _, err := labels.Parse(labelSelector); nil != err {
+		return nil, err
+	}
+	if _, err := fields.ParseSelector(fieldSelector); nil != err {
+		return nil, err
+	}
+	return func(options *meta_v1.ListOptions) {
+		options.LabelSelector = labelSelector
+		options.FieldSelector = fieldSelector
+	}, nil
+}
+
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func initKubeClient() (kubernetes.Interface, *rest.Config, error) {
 	var kubeconfig *string
 	var err error
 	var config *rest.Config
@@ -54,17 +193,17 @@ func initKubeClient() (kubernetes.Interface, error) {
 	if *kubeconfig != "" {
 		config, err = clientcmd.BuildConfigFromFlags("", *kubeconfig)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	} else {
 		config, err = rest.InClusterConfig()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return clientset, nil
+	return clientset, config, nil
 }
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSplitList(t *testing.T) {
+	cases := []struct {
+		s        string
+		excepted []string
+	}{
+		{"", []string{}},
+		{"default", []string{"default"}},
+		{" default, kube-system ,,", []string{"default", "kube-system"}},
+	}
+	for _, c := range cases {
+		if list := splitList(c.s); !reflect.DeepEqual(list, c.excepted) {
+			t.Fatalf("%q: excepted %v, got %v", c.s, c.excepted, list)
+		}
+	}
+}
+
+func TestListOptionsFunc(t *testing.T) {
+	tweak, err := listOptionsFunc("app=web,tier!=cache", "type=Warning")
+	if nil != err {
+		t.Fatal(err)
+	}
+	options := &meta_v1.ListOptions{}
+	tweak(options)
+	if options.LabelSelector != "app=web,tier!=cache" || options.FieldSelector != "type=Warning" {
+		t.Fatalf("excepted selectors applied, got %q %q", options.LabelSelector, options.FieldSelector)
+	}
+
+	for _, c := range [][2]string{{"app in (web", ""}, {"", "type"}} {
+		if _, err := listOptionsFunc(c[0], c[1]); nil == err {
+			t.Fatalf("excepted error of selectors %q %q", c[0], c[1])
+		}
+	}
+}
+
+func TestLabelSelectorOf(t *testing.T) {
+	if ls := labelSelectorOf("pods", "team=a", ""); ls != "team=a" {
+		t.Fatalf("excepted global label selector, got %q", ls)
+	}
+	if ls := labelSelectorOf("pods", "team=a", "app=web"); ls != "app=web" {
+		t.Fatalf("excepted own label selector, got %q", ls)
+	}
+	if ls := labelSelectorOf(eventsController, "team=a", ""); ls != "" {
+		t.Fatalf("excepted no label selector of events, got %q", ls)
+	}
+}
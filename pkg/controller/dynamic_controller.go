@@ -0,0 +1,342 @@
+package controller
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/model"
+	"github.com/jojohappy/luxun/pkg/stream"
+
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/jsonpath"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var dynamicWatches = flag.String("dynamic-watches", "", "path to the YAML config of custom resources to watch")
+
+// DynamicWatchConfig lists the resources watched by dynamic controllers,
+// e.g.
+//
+//	watches:
+//	- name: certificates
+//	  group: cert-manager.io
+//	  version: v1
+//	  resource: certificates
+//	  kind: Certificate
+//	  namespaced: true
+//	  rules:
+//	    status: '{.status.conditions[?(@.type=="Ready")].status}'
+//	    reason: '{.status.conditions[?(@.type=="Ready")].reason}'
+//	    message: '{.status.conditions[?(@.type=="Ready")].message}'
+//	  warningStatuses: ["False"]
+type DynamicWatchConfig struct {
+	Watches []DynamicWatch `yaml:"watches"`
+}
+
+// DynamicWatch is a resource watched by group, version and resource.
+// Namespace limits the watch to one namespace of a namespaced resource,
+// otherwise the namespaces flag applies. LabelSelector overrides the
+// label-selector flag.
+type DynamicWatch struct {
+	Name            string           `yaml:"name"`
+	Group           string           `yaml:"group"`
+	Version         string           `yaml:"version"`
+	Resource        string           `yaml:"resource"`
+	Kind            string           `yaml:"kind"`
+	Namespaced      bool             `yaml:"namespaced"`
+	Namespace       string           `yaml:"namespace"`
+	LabelSelector   string           `yaml:"labelSelector"`
+	FieldSelector   string           `yaml:"fieldSelector"`
+	Rules           DynamicWatchRule `yaml:"rules"`
+	WarningStatuses []string         `yaml:"warningStatuses"`
+}
+
+// DynamicWatchRule are the JSONPath templates extracting the fields of
+// events from an object. An event is emitted whenever the extracted
+// status, reason or message changes.
+type DynamicWatchRule struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+	Status    string `yaml:"status"`
+	Reason    string `yaml:"reason"`
+	Message   string `yaml:"message"`
+}
+
+func LoadDynamicWatchConfig(path string) (*DynamicWatchConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, fmt.Errorf("failed to read dynamic watch config: %v", err)
+	}
+	config := &DynamicWatchConfig{}
+	if err := yaml.UnmarshalStrict(content, config); nil != err {
+		return nil, fmt.Errorf("failed to parse dynamic watch config: %v", err)
+	}
+	return config, nil
+}
+
+type DynamicController struct {
+	watch    DynamicWatch
+	informer cache.SharedIndexInformer
+	started  time.Time
+
+	name      *jsonpath.JSONPath
+	namespace *jsonpath.JSONPath
+	status    *jsonpath.JSONPath
+	reason    *jsonpath.JSONPath
+	message   *jsonpath.JSONPath
+}
+
+// newDynamicControllers builds a controller for every watch of the config
+// at path, named dynamic/<name>, or dynamic/<name>/<namespace> for each of
+// namespaces.
+func newDynamicControllers(config *rest.Config, path string, namespaces []string, labelSelector string) (map[string]cache.Controller, error) {
+	controllers := make(map[string]cache.Controller)
+	if path == "" {
+		return controllers, nil
+	}
+	watchConfig, err := LoadDynamicWatchConfig(path)
+	if nil != err {
+		return nil, err
+	}
+	pool := dynamic.NewDynamicClientPool(config)
+	names := make(map[string]bool)
+	for i, w := range watchConfig.Watches {
+		if names[w.Name] {
+			return nil, fmt.Errorf("duplicated dynamic watch %s", w.Name)
+		}
+		names[w.Name] = true
+		if w.LabelSelector == "" {
+			w.LabelSelector = labelSelector
+		}
+		if !w.Namespaced || w.Namespace != "" || len(namespaces) == 0 {
+			dc, err := newDynamicController(pool, w)
+			if nil != err {
+				return nil, fmt.Errorf("invalid dynamic watch %d: %v", i, err)
+			}
+			controllers["dynamic/"+w.Name] = dc
+			continue
+		}
+		for _, ns := range namespaces {
+			w.Namespace = ns
+			dc, err := newDynamicController(pool, w)
+			if nil != err {
+				return nil, fmt.Errorf("invalid dynamic watch %d: %v", i, err)
+			}
+			controllers["dynamic/"+w.Name+"/"+ns] = dc
+		}
+	}
+	return controllers, nil
+}
+
+func newDynamicController(pool dynamic.ClientPool, w DynamicWatch) (*DynamicController, error) {
+	if w.Name == "" || w.Version == "" || w.Resource == "" {
+		return nil, fmt.Errorf("name, version and resource are required")
+	}
+	if w.Namespace != "" && !w.Namespaced {
+		return nil, fmt.Errorf("namespace of cluster scoped resource %s", w.Resource)
+	}
+	tweak, err := listOptionsFunc(w.LabelSelector, w.FieldSelector)
+	if nil != err {
+		return nil, err
+	}
+	if w.Rules.Name == "" {
+		w.Rules.Name = "{.metadata.name}"
+	}
+	if w.Rules.Namespace == "" {
+		w.Rules.Namespace = "{.metadata.namespace}"
+	}
+
+	dc := &DynamicController{watch: w}
+	for _, rule := range []struct {
+		j    **jsonpath.JSONPath
+		name string
+		text string
+	}{
+		{&dc.name, "name", w.Rules.Name},
+		{&dc.namespace, "namespace", w.Rules.Namespace},
+		{&dc.status, "status", w.Rules.Status},
+		{&dc.reason, "reason", w.Rules.Reason},
+		{&dc.message, "message", w.Rules.Message},
+	} {
+		if *rule.j, err = compileJSONPath(rule.name, rule.text); nil != err {
+			return nil, err
+		}
+	}
+
+	gvr := schema.GroupVersionResource{Group: w.Group, Version: w.Version, Resource: w.Resource}
+	client, err := pool.ClientForGroupVersionResource(gvr)
+	if nil != err {
+		return nil, err
+	}
+	rc := client.Resource(&meta_v1.APIResource{Name: w.Resource, Namespaced: w.Namespaced, Kind: w.Kind}, w.Namespace)
+	dc.informer = cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+				tweak(&options)
+				return rc.List(options)
+			},
+			WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+				tweak(&options)
+				return rc.Watch(options)
+			},
+		},
+		&unstructured.Unstructured{},
+		DefaultResyncPeriod,
+		cache.Indexers{},
+	)
+	dc.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{dc.OnAdd, dc.OnUpdate, dc.OnDelete})
+	return dc, nil
+}
+
+func compileJSONPath(name, text string) (*jsonpath.JSONPath, error) {
+	if text == "" {
+		return nil, nil
+	}
+	j := jsonpath.New(name)
+	j.AllowMissingKeys(true)
+	if err := j.Parse(text); nil != err {
+		return nil, fmt.Errorf("invalid %s rule %q: %v", name, text, err)
+	}
+	return j, nil
+}
+
+func extract(j *jsonpath.JSONPath, content map[string]interface{}) string {
+	if nil == j {
+		return ""
+	}
+	buf := &bytes.Buffer{}
+	if err := j.Execute(buf, content); nil != err {
+		return ""
+	}
+	return buf.String()
+}
+
+func (dc *DynamicController) Run(stopCh <-chan struct{}) {
+	fmt.Printf("start dynamic controller %s\n", dc.watch.Name)
+	dc.started = time.Now()
+	go dc.informer.Run(stopCh)
+
+	if !cache.WaitForCacheSync(stopCh, dc.HasSynced) {
+		fmt.Println("timed out waiting for caches to sync")
+		return
+	}
+
+	fmt.Printf("dynamic controller %s synced and ready\n", dc.watch.Name)
+
+	<-stopCh
+}
+
+func (dc *DynamicController) HasSynced() bool {
+	return dc.informer.HasSynced()
+}
+
+func (dc *DynamicController) LastSyncResourceVersion() string {
+	return dc.informer.LastSyncResourceVersion()
+}
+
+// OnAdd emits an event for objects created after the controller started,
+// the objects listed on start are not new.
+func (dc *DynamicController) OnAdd(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		fmt.Println("converting to Unstructured object failed in OnAdd", "obj", obj)
+		return
+	}
+	if u.GetCreationTimestamp().Time.Before(dc.started) {
+		return
+	}
+	dc.process(dc.convert(u, "Add"))
+}
+
+func (dc *DynamicController) OnUpdate(oldObj, newObj interface{}) {
+	old, ok := oldObj.(*unstructured.Unstructured)
+	if !ok {
+		fmt.Println("converting to Unstructured object failed in OnUpdate", "obj", oldObj)
+		return
+	}
+	u, ok := newObj.(*unstructured.Unstructured)
+	if !ok {
+		fmt.Println("converting to Unstructured object failed in OnUpdate", "obj", newObj)
+		return
+	}
+	if ev := dc.changed(old, u); nil != ev {
+		dc.process(ev)
+	}
+}
+
+// changed returns the event of u if its status, reason or message differs
+// from the one of old, or nil.
+func (dc *DynamicController) changed(old, u *unstructured.Unstructured) *model.Event {
+	oldEv, ev := dc.convert(old, "Update"), dc.convert(u, "Update")
+	if oldEv.Status == ev.Status && oldEv.Reason == ev.Reason && oldEv.Message == ev.Message {
+		return nil
+	}
+	return ev
+}
+
+func (dc *DynamicController) OnDelete(obj interface{}) {
+	if deletedState, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = deletedState.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		fmt.Println("converting to Unstructured object failed in OnDelete", "obj", obj)
+		return
+	}
+	dc.process(dc.convert(u, "Delete"))
+}
+
+func (dc *DynamicController) process(ev *model.Event) {
+	if err := stream.Process(ev); nil != err {
+		fmt.Println("failed to process", dc.watch.Name, ev.ObjectName, "err", err)
+	}
+}
+
+func (dc *DynamicController) convert(u *unstructured.Unstructured, action string) *model.Event {
+	content := u.UnstructuredContent()
+	kind := dc.watch.Kind
+	if kind == "" {
+		kind = u.GetKind()
+	}
+	name := extract(dc.name, content)
+	ev := &model.Event{
+		Time:              time.Now(),
+		UID:               string(u.GetUID()),
+		Name:              name,
+		Namespace:         extract(dc.namespace, content),
+		CreationTimestamp: u.GetCreationTimestamp().Time,
+		Labels:            make(map[int]model.KVObject),
+		Kind:              kind,
+		ObjectName:        name,
+		Reason:            extract(dc.reason, content),
+		Message:           extract(dc.message, content),
+		Status:            extract(dc.status, content),
+		Type:              core_v1.EventTypeNormal,
+		Action:            action,
+		Env:               model.GetEnv(),
+	}
+	for _, s := range dc.watch.WarningStatuses {
+		if ev.Status == s {
+			ev.Type = core_v1.EventTypeWarning
+		}
+	}
+
+	i := 0
+	for k, v := range u.GetLabels() {
+		ev.Labels[i] = model.KVObject{k, v}
+		i++
+	}
+	return ev
+}
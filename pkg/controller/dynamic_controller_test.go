@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+const certificateWatch = `
+watches:
+- name: certificates
+  group: cert-manager.io
+  version: v1
+  resource: certificates
+  kind: Certificate
+  namespaced: true
+  rules:
+    status: '{.status.conditions[?(@.type=="Ready")].status}'
+    reason: '{.status.conditions[?(@.type=="Ready")].reason}'
+    message: '{.status.conditions[?(@.type=="Ready")].message}'
+  warningStatuses: ["False"]
+`
+
+func loadWatches(t *testing.T, content string) (*DynamicWatchConfig, error) {
+	dir, err := ioutil.TempDir("", "dynamic")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "watches.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); nil != err {
+		t.Fatal(err)
+	}
+	return LoadDynamicWatchConfig(path)
+}
+
+func testClientPool() dynamic.ClientPool {
+	return dynamic.NewDynamicClientPool(&rest.Config{Host: "http://127.0.0.1:8080"})
+}
+
+func TestLoadDynamicWatchConfig(t *testing.T) {
+	config, err := loadWatches(t, certificateWatch)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(config.Watches) != 1 || config.Watches[0].Resource != "certificates" || config.Watches[0].WarningStatuses[0] != "False" {
+		t.Fatalf("excepted the watch of certificates, got %+v", config.Watches)
+	}
+
+	if _, err := loadWatches(t, "watches:\n- name: certificates\n  selector: app=web\n"); nil == err {
+		t.Fatalf("excepted error of unknown field")
+	}
+	if _, err := LoadDynamicWatchConfig("/nonexistent/watches.yaml"); nil == err {
+		t.Fatalf("excepted error of missing file")
+	}
+}
+
+func TestNewDynamicController(t *testing.T) {
+	valid := DynamicWatch{Name: "certificates", Group: "cert-manager.io", Version: "v1", Resource: "certificates", Namespaced: true}
+	cases := []struct {
+		name  string
+		watch func(w DynamicWatch) DynamicWatch
+		valid bool
+	}{
+		{"valid", func(w DynamicWatch) DynamicWatch { return w }, true},
+		{"namespace", func(w DynamicWatch) DynamicWatch { w.Namespace = "default"; return w }, true},
+		{"missing name", func(w DynamicWatch) DynamicWatch { w.Name = ""; return w }, false},
+		{"missing version", func(w DynamicWatch) DynamicWatch { w.Version = ""; return w }, false},
+		{"missing resource", func(w DynamicWatch) DynamicWatch { w.Resource = ""; return w }, false},
+		{"namespace of cluster scoped", func(w DynamicWatch) DynamicWatch { w.Namespaced = false; w.Namespace = "default"; return w }, false},
+		{"bad label selector", func(w DynamicWatch) DynamicWatch { w.LabelSelector = "app in (web"; return w }, false},
+		{"bad status rule", func(w DynamicWatch) DynamicWatch { w.Rules.Status = "{.status.conditions[}"; return w }, false},
+		{"bad name rule", func(w DynamicWatch) DynamicWatch { w.Rules.Name = "{.metadata.name"; return w }, false},
+	}
+	pool := testClientPool()
+	for _, c := range cases {
+		_, err := newDynamicController(pool, c.watch(valid))
+		if c.valid && nil != err {
+			t.Fatalf("%s: excepted valid watch, got %v", c.name, err)
+		}
+		if !c.valid && nil == err {
+			t.Fatalf("%s: excepted error of invalid watch", c.name)
+		}
+	}
+}
+
+func certificate(status, reason, message string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata": map[string]interface{}{
+			"name":      "web-tls",
+			"namespace": "default",
+			"uid":       "6c1ff9a4",
+			"labels":    map[string]interface{}{"app": "web"},
+		},
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Issuing", "status": "True"},
+				map[string]interface{}{"type": "Ready", "status": status, "reason": reason, "message": message},
+			},
+		},
+	}}
+	return u
+}
+
+func TestDynamicControllerConvert(t *testing.T) {
+	config, err := loadWatches(t, certificateWatch)
+	if nil != err {
+		t.Fatal(err)
+	}
+	dc, err := newDynamicController(testClientPool(), config.Watches[0])
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	ready := certificate("True", "Ready", "Certificate is up to date")
+	ev := dc.convert(ready, "Add")
+	if ev.Kind != "Certificate" || ev.Namespace != "default" || ev.ObjectName != "web-tls" || ev.UID != "6c1ff9a4" || ev.Action != "Add" {
+		t.Fatalf("excepted event of the certificate, got %+v", ev)
+	}
+	if ev.Status != "True" || ev.Reason != "Ready" || ev.Message != "Certificate is up to date" || ev.Type != core_v1.EventTypeNormal {
+		t.Fatalf("excepted fields extracted by rules, got %+v", ev)
+	}
+	if len(ev.Labels) != 1 || ev.Labels[0].Key != "app" {
+		t.Fatalf("excepted labels of the certificate, got %v", ev.Labels)
+	}
+
+	if nil != dc.changed(ready, certificate("True", "Ready", "Certificate is up to date")) {
+		t.Fatalf("excepted no event of an unchanged status")
+	}
+	expired := certificate("False", "Expired", "Certificate has expired")
+	ev = dc.changed(ready, expired)
+	if nil == ev || ev.Status != "False" || ev.Reason != "Expired" || ev.Type != core_v1.EventTypeWarning || ev.Action != "Update" {
+		t.Fatalf("excepted warning of the expired certificate, got %+v", ev)
+	}
+	if nil == dc.changed(expired, certificate("False", "Expired", "Certificate expired on 2018-03-01")) {
+		t.Fatalf("excepted event of a changed message")
+	}
+}
@@ -22,15 +22,14 @@ type EventController struct {
 }
 
 func init() {
-	RegisterController("events", NewEventController)
+	RegisterController(eventsController, NewEventController)
 }
 
-func NewEventController(client kubernetes.Interface) cache.Controller {
-	return newEventController(client)
+func NewEventController(client kubernetes.Interface, f informers.SharedInformerFactory) cache.Controller {
+	return newEventController(client, f)
 }
 
-func newEventController(client kubernetes.Interface) *EventController {
-	f := informers.NewSharedInformerFactory(client, DefaultResyncPeriod)
+func newEventController(client kubernetes.Interface, f informers.SharedInformerFactory) *EventController {
 	ec := &EventController{
 		informer: f.Core().V1().Events().Informer(),
 		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
@@ -54,7 +53,8 @@ func (ec *EventController) Run(stopCh <-chan struct{}) {
 
 	fmt.Println("event controller synced and ready")
 
-	wait.Until(ec.worker, time.Second, stopCh)
+	go wait.Until(ec.worker, time.Second, stopCh)
+	<-stopCh
 }
 
 func (ec *EventController) HasSynced() bool {
@@ -73,7 +73,6 @@ func (ec *EventController) worker() {
 func (ec *EventController) nextWork() bool {
 	key, quit := ec.queue.Get()
 	if quit {
-		fmt.Println("unexpected quit of queue")
 		return false
 	}
 	defer ec.queue.Done(key)
@@ -96,10 +95,17 @@ func (ec *EventController) processItem(key string) error {
 		return fmt.Errorf("error fetching object with key %s from store: %v", key, err)
 	}
 	ev, ok := obj.(*core_v1.Event)
-	if ok {
-		stream.Process(model.ConvertEvent(ev))
+	if !ok {
+		return nil
 	}
-	return nil
+	// events dropped by the overflow policy are counted as dropped already,
+	// retrying them would deliver them after all
+	err = stream.Process(model.ConvertEvent(ev))
+	if err == stream.ErrDropped || err == stream.ErrStopped {
+		fmt.Printf("event %s is not processed: %v\n", key, err)
+		return nil
+	}
+	return err
 }
 
 func (ec *EventController) OnAdd(obj interface{}) {
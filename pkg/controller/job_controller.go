@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/model"
+	"github.com/jojohappy/luxun/pkg/storage"
+	"github.com/jojohappy/luxun/pkg/stream"
+
+	batch_v1 "k8s.io/api/batch/v1"
+	batch_v1beta1 "k8s.io/api/batch/v1beta1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	cronJobCheckInterval = flag.Duration("cronjob-check-interval", time.Minute, "interval of checking cron jobs for missed schedules")
+	cronJobMissedGrace   = flag.Duration("cronjob-missed-grace", 5*time.Minute, "time after which a schedule of a cron job without starting deadline is missed")
+)
+
+type JobController struct {
+	informer cache.SharedIndexInformer
+	client   kubernetes.Interface
+}
+
+// CronJobController watches cron jobs, which are served from batch/v1beta1
+// by this version of the api.
+type CronJobController struct {
+	informer cache.SharedIndexInformer
+	client   kubernetes.Interface
+
+	lock     sync.Mutex
+	reported map[string]time.Time
+}
+
+func init() {
+	RegisterController("jobs", NewJobController)
+	RegisterController("cronjobs", NewCronJobController)
+}
+
+func NewJobController(client kubernetes.Interface, f informers.SharedInformerFactory) cache.Controller {
+	jc := &JobController{
+		informer: f.Batch().V1().Jobs().Informer(),
+		client:   client,
+	}
+
+	jc.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{UpdateFunc: jc.OnUpdate})
+	return jc
+}
+
+func (jc *JobController) Run(stopCh <-chan struct{}) {
+	fmt.Println("start job controller")
+	go jc.informer.Run(stopCh)
+
+	if !cache.WaitForCacheSync(stopCh, jc.HasSynced) {
+		fmt.Println("timed out waiting for caches to sync")
+		return
+	}
+
+	fmt.Println("job controller synced and ready")
+
+	<-stopCh
+}
+
+func (jc *JobController) HasSynced() bool {
+	return jc.informer.HasSynced()
+}
+
+func (jc *JobController) LastSyncResourceVersion() string {
+	return jc.informer.LastSyncResourceVersion()
+}
+
+func (jc *JobController) OnUpdate(oldObj, newObj interface{}) {
+	oldJob, ok := oldObj.(*batch_v1.Job)
+	if !ok {
+		fmt.Println("converting to Job object failed in OnUpdate", "obj", oldObj)
+		return
+	}
+	newJob, ok := newObj.(*batch_v1.Job)
+	if !ok {
+		fmt.Println("converting to Job object failed in OnUpdate", "obj", newObj)
+		return
+	}
+	events := model.ConvertJobEvents(oldJob, newJob)
+	if len(events) == 0 {
+		return
+	}
+	for _, ev := range events {
+		if ev.Reason != model.JobCompleted && ev.Reason != model.JobFailed {
+			continue
+		}
+		// jobs are counted by their cron job, names of other jobs are
+		// mostly generated and would make a series per job
+		key := storage.JobKey{Namespace: newJob.Namespace, Name: ev.Job.CronJob}
+		status := "succeeded"
+		if ev.Reason == model.JobFailed {
+			status = "failed"
+		}
+		storage.JobStorageInst().AddResult(key, status, ev.Job.DurationSeconds)
+	}
+	if err := stream.Process(events...); nil != err {
+		fmt.Println("failed to process job", newJob.Name, "err", err)
+	}
+}
+
+func NewCronJobController(client kubernetes.Interface, f informers.SharedInformerFactory) cache.Controller {
+	cc := &CronJobController{
+		informer: f.Batch().V1beta1().CronJobs().Informer(),
+		client:   client,
+		reported: make(map[string]time.Time),
+	}
+
+	cc.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{cc.OnAdd, cc.OnUpdate, cc.OnDelete})
+	return cc
+}
+
+func (cc *CronJobController) Run(stopCh <-chan struct{}) {
+	fmt.Println("start cronjob controller")
+	go cc.informer.Run(stopCh)
+
+	if !cache.WaitForCacheSync(stopCh, cc.HasSynced) {
+		fmt.Println("timed out waiting for caches to sync")
+		return
+	}
+
+	fmt.Println("cronjob controller synced and ready")
+
+	ticker := time.NewTicker(*cronJobCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cc.checkMissedSchedules(time.Now())
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (cc *CronJobController) HasSynced() bool {
+	return cc.informer.HasSynced()
+}
+
+func (cc *CronJobController) LastSyncResourceVersion() string {
+	return cc.informer.LastSyncResourceVersion()
+}
+
+// checkMissedSchedules emits an event for every cron job with schedules
+// missed since the last check.
+func (cc *CronJobController) checkMissedSchedules(now time.Time) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	for _, obj := range cc.informer.GetStore().List() {
+		cj, ok := obj.(*batch_v1beta1.CronJob)
+		if !ok {
+			continue
+		}
+		// only the schedules missed since the last report are counted
+		key := cj.Namespace + "/" + cj.Name
+		missed, count, err := model.CronJobMissedSchedules(cj, now, *cronJobMissedGrace, cc.reported[key])
+		if nil != err {
+			fmt.Println("failed to check schedule of cronjob", cj.Name, "err", err)
+			continue
+		}
+		if count == 0 {
+			continue
+		}
+		cc.reported[key] = missed
+		storage.JobStorageInst().AddMissed(storage.JobKey{Namespace: cj.Namespace, Name: cj.Name}, count)
+		if err := stream.Process(model.ConvertCronJobMissedEvent(cj, missed, count)); nil != err {
+			fmt.Println("failed to process cronjob", cj.Name, "err", err)
+		}
+	}
+}
+
+func (cc *CronJobController) OnAdd(obj interface{}) {
+	cj, ok := obj.(*batch_v1beta1.CronJob)
+	if !ok {
+		fmt.Println("converting to CronJob object failed in OnAdd", "obj", obj)
+		return
+	}
+	storage.JobStorageInst().SetSuspended(storage.JobKey{Namespace: cj.Namespace, Name: cj.Name}, nil != cj.Spec.Suspend && *cj.Spec.Suspend)
+}
+
+func (cc *CronJobController) OnUpdate(oldObj, newObj interface{}) {
+	oldCronJob, ok := oldObj.(*batch_v1beta1.CronJob)
+	if !ok {
+		fmt.Println("converting to CronJob object failed in OnUpdate", "obj", oldObj)
+		return
+	}
+	newCronJob, ok := newObj.(*batch_v1beta1.CronJob)
+	if !ok {
+		fmt.Println("converting to CronJob object failed in OnUpdate", "obj", newObj)
+		return
+	}
+	storage.JobStorageInst().SetSuspended(storage.JobKey{Namespace: newCronJob.Namespace, Name: newCronJob.Name}, nil != newCronJob.Spec.Suspend && *newCronJob.Spec.Suspend)
+	events := model.ConvertCronJobEvents(oldCronJob, newCronJob)
+	if len(events) == 0 {
+		return
+	}
+	if err := stream.Process(events...); nil != err {
+		fmt.Println("failed to process cronjob", newCronJob.Name, "err", err)
+	}
+}
+
+func (cc *CronJobController) OnDelete(obj interface{}) {
+	if deletedState, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = deletedState.Obj
+	}
+	cj, ok := obj.(*batch_v1beta1.CronJob)
+	if !ok {
+		fmt.Println("converting to CronJob object failed in OnDelete", "obj", obj)
+		return
+	}
+	cc.lock.Lock()
+	delete(cc.reported, cj.Namespace+"/"+cj.Name)
+	cc.lock.Unlock()
+	storage.JobStorageInst().RemoveCronJob(storage.JobKey{Namespace: cj.Namespace, Name: cj.Name})
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/jojohappy/luxun/pkg/model"
+	"github.com/jojohappy/luxun/pkg/storage"
+	"github.com/jojohappy/luxun/pkg/stream"
+
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+type NodeController struct {
+	informer cache.SharedIndexInformer
+	client   kubernetes.Interface
+}
+
+func init() {
+	RegisterClusterController("nodes", NewNodeController)
+}
+
+func NewNodeController(client kubernetes.Interface, f informers.SharedInformerFactory) cache.Controller {
+	return newNodeController(client, f)
+}
+
+func newNodeController(client kubernetes.Interface, f informers.SharedInformerFactory) *NodeController {
+	nc := &NodeController{
+		informer: f.Core().V1().Nodes().Informer(),
+		client:   client,
+	}
+
+	nc.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{nc.OnAdd, nc.OnUpdate, nc.OnDelete})
+	return nc
+}
+
+func (nc *NodeController) Run(stopCh <-chan struct{}) {
+	fmt.Println("start node controller")
+	go nc.informer.Run(stopCh)
+
+	if !cache.WaitForCacheSync(stopCh, nc.HasSynced) {
+		fmt.Println("timed out waiting for caches to sync")
+		return
+	}
+
+	fmt.Println("node controller synced and ready")
+
+	<-stopCh
+}
+
+func (nc *NodeController) HasSynced() bool {
+	return nc.informer.HasSynced()
+}
+
+func (nc *NodeController) LastSyncResourceVersion() string {
+	return nc.informer.LastSyncResourceVersion()
+}
+
+func (nc *NodeController) OnAdd(obj interface{}) {
+	node, err := convertToNode(obj)
+	if err != nil {
+		fmt.Println("converting to Node object failed in OnAdd", "err", err)
+		return
+	}
+	storeNodeStatus(node)
+}
+
+func (nc *NodeController) OnUpdate(oldObj, newObj interface{}) {
+	oldNode, err := convertToNode(oldObj)
+	if err != nil {
+		fmt.Println("converting to Node object failed in OnUpdate", "err", err)
+		return
+	}
+	newNode, err := convertToNode(newObj)
+	if err != nil {
+		fmt.Println("converting to Node object failed in OnUpdate", "err", err)
+		return
+	}
+	storeNodeStatus(newNode)
+
+	events := model.ConvertNodeEvents(oldNode, newNode)
+	for _, ev := range events {
+		if nil != ev.NodeCondition {
+			storage.NodeStorageInst().AddTransition(ev.NodeCondition.Type, ev.NodeCondition.Status)
+		}
+	}
+	if len(events) == 0 {
+		return
+	}
+	if err := stream.Process(events...); nil != err {
+		fmt.Println("failed to process node", newNode.Name, "err", err)
+	}
+}
+
+func (nc *NodeController) OnDelete(obj interface{}) {
+	node, err := convertToNode(obj)
+	if err != nil {
+		fmt.Println("converting to Node object failed in OnDelete", "err", err)
+		return
+	}
+	storage.NodeStorageInst().Remove(node.Name)
+	if err := stream.Process(model.ConvertNodeDeleteEvent(node)); nil != err {
+		fmt.Println("failed to process node", node.Name, "err", err)
+	}
+}
+
+func storeNodeStatus(node *core_v1.Node) {
+	status := storage.NodeStatus{
+		Conditions:    make(map[string]string),
+		Unschedulable: node.Spec.Unschedulable,
+		Taints:        len(node.Spec.Taints),
+	}
+	for _, c := range node.Status.Conditions {
+		for _, t := range model.NodeConditionTypes {
+			if c.Type == t {
+				status.Conditions[string(c.Type)] = string(c.Status)
+			}
+		}
+	}
+	storage.NodeStorageInst().Set(node.Name, status)
+}
+
+func convertToNode(o interface{}) (*core_v1.Node, error) {
+	node, ok := o.(*core_v1.Node)
+	if ok {
+		return node, nil
+	}
+
+	deletedState, ok := o.(cache.DeletedFinalStateUnknown)
+	if !ok {
+		return nil, fmt.Errorf("Received unexpected object: %v", o)
+	}
+	node, ok = deletedState.Obj.(*core_v1.Node)
+	if !ok {
+		return nil, fmt.Errorf("DeletedFinalStateUnknown contained non-Node object: %v", deletedState.Obj)
+	}
+	return node, nil
+}
@@ -21,12 +21,11 @@ func init() {
 	RegisterController("pods", NewPodController)
 }
 
-func NewPodController(client kubernetes.Interface) cache.Controller {
-	return newPodController(client)
+func NewPodController(client kubernetes.Interface, f informers.SharedInformerFactory) cache.Controller {
+	return newPodController(client, f)
 }
 
-func newPodController(client kubernetes.Interface) *PodController {
-	f := informers.NewSharedInformerFactory(client, DefaultResyncPeriod)
+func newPodController(client kubernetes.Interface, f informers.SharedInformerFactory) *PodController {
 	pc := &PodController{
 		informer: f.Core().V1().Pods().Informer(),
 		client:   client,
@@ -66,7 +65,9 @@ func (pc *PodController) OnUpdate(_, newObj interface{}) {
 		fmt.Println("converting to Pod object failed in OnUpdate", "err", err)
 		return
 	}
-	stream.Process(model.ConvertPodEvent(pod))
+	if err := stream.Process(model.ConvertPodEvent(pod)); nil != err {
+		fmt.Println("failed to process pod", pod.Name, "err", err)
+	}
 }
 
 func (pc *PodController) OnDelete(obj interface{}) {
@@ -75,7 +76,9 @@ func (pc *PodController) OnDelete(obj interface{}) {
 		fmt.Println("converting to Pod object failed in OnDelete", "err", err)
 		return
 	}
-	stream.Process(model.ConvertPodDeleteEvent(pod))
+	if err := stream.Process(model.ConvertPodDeleteEvent(pod)); nil != err {
+		fmt.Println("failed to process pod", pod.Name, "err", err)
+	}
 }
 
 func convertToPod(o interface{}) (*core_v1.Pod, error) {
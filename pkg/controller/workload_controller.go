@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/jojohappy/luxun/pkg/model"
+	"github.com/jojohappy/luxun/pkg/stream"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// WorkloadController emits the rollout events of deployments, stateful
+// sets or daemon sets.
+type WorkloadController struct {
+	kind     string
+	informer cache.SharedIndexInformer
+	client   kubernetes.Interface
+	convert  func(obj interface{}) (model.WorkloadStatus, error)
+	tracker  *model.RolloutTracker
+}
+
+func init() {
+	RegisterController("deployments", NewDeploymentController)
+	RegisterController("statefulsets", NewStatefulSetController)
+	RegisterController("daemonsets", NewDaemonSetController)
+}
+
+func NewDeploymentController(client kubernetes.Interface, f informers.SharedInformerFactory) cache.Controller {
+	return newWorkloadController("deployment", f.Apps().V1().Deployments().Informer(), client, convertDeployment)
+}
+
+func NewStatefulSetController(client kubernetes.Interface, f informers.SharedInformerFactory) cache.Controller {
+	return newWorkloadController("statefulset", f.Apps().V1().StatefulSets().Informer(), client, convertStatefulSet)
+}
+
+func NewDaemonSetController(client kubernetes.Interface, f informers.SharedInformerFactory) cache.Controller {
+	return newWorkloadController("daemonset", f.Apps().V1().DaemonSets().Informer(), client, convertDaemonSet)
+}
+
+func newWorkloadController(kind string, informer cache.SharedIndexInformer, client kubernetes.Interface, convert func(obj interface{}) (model.WorkloadStatus, error)) *WorkloadController {
+	wc := &WorkloadController{
+		kind:     kind,
+		informer: informer,
+		client:   client,
+		convert:  convert,
+		tracker:  model.NewRolloutTracker(),
+	}
+
+	wc.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{wc.OnAdd, wc.OnUpdate, wc.OnDelete})
+	return wc
+}
+
+func (wc *WorkloadController) Run(stopCh <-chan struct{}) {
+	fmt.Printf("start %s controller\n", wc.kind)
+	go wc.informer.Run(stopCh)
+
+	if !cache.WaitForCacheSync(stopCh, wc.HasSynced) {
+		fmt.Println("timed out waiting for caches to sync")
+		return
+	}
+
+	fmt.Printf("%s controller synced and ready\n", wc.kind)
+
+	<-stopCh
+}
+
+func (wc *WorkloadController) HasSynced() bool {
+	return wc.informer.HasSynced()
+}
+
+func (wc *WorkloadController) LastSyncResourceVersion() string {
+	return wc.informer.LastSyncResourceVersion()
+}
+
+func (wc *WorkloadController) OnAdd(obj interface{}) {
+	ws, err := wc.convert(obj)
+	if err != nil {
+		fmt.Println("converting to", wc.kind, "object failed in OnAdd", "err", err)
+		return
+	}
+	wc.tracker.Add(ws)
+}
+
+func (wc *WorkloadController) OnUpdate(oldObj, newObj interface{}) {
+	old, err := wc.convert(oldObj)
+	if err != nil {
+		fmt.Println("converting to", wc.kind, "object failed in OnUpdate", "err", err)
+		return
+	}
+	ws, err := wc.convert(newObj)
+	if err != nil {
+		fmt.Println("converting to", wc.kind, "object failed in OnUpdate", "err", err)
+		return
+	}
+	events := wc.tracker.Update(old, ws)
+	if len(events) == 0 {
+		return
+	}
+	if err := stream.Process(events...); nil != err {
+		fmt.Println("failed to process", wc.kind, ws.Name, "err", err)
+	}
+}
+
+func (wc *WorkloadController) OnDelete(obj interface{}) {
+	ws, err := wc.convert(obj)
+	if err != nil {
+		fmt.Println("converting to", wc.kind, "object failed in OnDelete", "err", err)
+		return
+	}
+	wc.tracker.Delete(ws)
+}
+
+func deletedObject(o interface{}) interface{} {
+	if deletedState, ok := o.(cache.DeletedFinalStateUnknown); ok {
+		return deletedState.Obj
+	}
+	return o
+}
+
+func convertDeployment(o interface{}) (model.WorkloadStatus, error) {
+	d, ok := deletedObject(o).(*apps_v1.Deployment)
+	if !ok {
+		return model.WorkloadStatus{}, fmt.Errorf("Received unexpected object: %v", o)
+	}
+	return model.ConvertDeploymentStatus(d), nil
+}
+
+func convertStatefulSet(o interface{}) (model.WorkloadStatus, error) {
+	s, ok := deletedObject(o).(*apps_v1.StatefulSet)
+	if !ok {
+		return model.WorkloadStatus{}, fmt.Errorf("Received unexpected object: %v", o)
+	}
+	return model.ConvertStatefulSetStatus(s), nil
+}
+
+func convertDaemonSet(o interface{}) (model.WorkloadStatus, error) {
+	d, ok := deletedObject(o).(*apps_v1.DaemonSet)
+	if !ok {
+		return model.WorkloadStatus{}, fmt.Errorf("Received unexpected object: %v", o)
+	}
+	return model.ConvertDaemonSetStatus(d), nil
+}
@@ -0,0 +1,460 @@
+package deadletter
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/handler"
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+const (
+	fileSuffix      = ".jsonl"
+	replayingSuffix = ".replaying"
+	rejectedSuffix  = ".rejected"
+
+	// replayBatch is the max number of dead letters replayed before
+	// waiting for their delivery.
+	replayBatch = 1000
+)
+
+var (
+	deadLetterDir            = flag.String("dead-letter-dir", "", "directory that events failed to reach a sink are persisted to, empty disables the dead letter queue")
+	deadLetterMaxEvents      = flag.Int("dead-letter-max-events", 100000, "max number of events kept per sink, newer events are dropped once it is reached")
+	deadLetterReplayInterval = flag.Duration("dead-letter-replay-interval", time.Minute, "interval of replaying dead letters back into their sink")
+	deadLetterMaxAttempts    = flag.Int("dead-letter-max-attempts", 10, "max number of replays of an event before it is rejected, 0 means no limit")
+	deadLetterReplayTimeout  = flag.Duration("dead-letter-replay-timeout", 30*time.Second, "max time waiting for a batch of replayed events to be delivered, the rest is replayed again later, 0 waits forever")
+)
+
+var (
+	errReplayTimeout = fmt.Errorf("timed out waiting for delivery")
+	errNotReplayed   = fmt.Errorf("not replayed")
+)
+
+// Record is a dead letter persisted on disk. Attempts is the number of
+// times it has been replayed.
+type Record struct {
+	Time     time.Time    `json:"time"`
+	Sink     string       `json:"sink"`
+	Reason   string       `json:"reason"`
+	Attempts int          `json:"attempts,omitempty"`
+	Event    *model.Event `json:"event"`
+}
+
+// Queue persists dead letters in one JSON lines file per sink. Events
+// failed for good, rejected by the sink or out of attempts, are moved to
+// a file of their own which is never replayed.
+type Queue struct {
+	dir           string
+	maxEvents     int
+	maxAttempts   int
+	replayTimeout time.Duration
+	lock          sync.Mutex
+	counts        map[string]int
+	rejected      map[string]int
+}
+
+var defaultQueue *Queue
+
+func New(dir string, maxEvents, maxAttempts int, replayTimeout time.Duration) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		return nil, err
+	}
+	q := &Queue{
+		dir:           dir,
+		maxEvents:     maxEvents,
+		maxAttempts:   maxAttempts,
+		replayTimeout: replayTimeout,
+		counts:        make(map[string]int),
+		rejected:      make(map[string]int),
+	}
+
+	// events being replayed when we were stopped are put back first
+	replaying, err := filepath.Glob(filepath.Join(dir, "*"+fileSuffix+replayingSuffix))
+	if nil != err {
+		return nil, err
+	}
+	for _, path := range replaying {
+		if err := q.restore(path); nil != err {
+			return nil, err
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+fileSuffix))
+	if nil != err {
+		return nil, err
+	}
+	for _, path := range files {
+		n, err := countLines(path)
+		if nil != err {
+			return nil, err
+		}
+		q.counts[strings.TrimSuffix(filepath.Base(path), fileSuffix)] = n
+	}
+
+	rejected, err := filepath.Glob(filepath.Join(dir, "*"+fileSuffix+rejectedSuffix))
+	if nil != err {
+		return nil, err
+	}
+	for _, path := range rejected {
+		n, err := countLines(path)
+		if nil != err {
+			return nil, err
+		}
+		q.rejected[strings.TrimSuffix(filepath.Base(path), fileSuffix+rejectedSuffix)] = n
+	}
+	return q, nil
+}
+
+// Init creates the default queue if a directory is configured.
+func Init() error {
+	if *deadLetterDir == "" {
+		return nil
+	}
+	q, err := New(*deadLetterDir, *deadLetterMaxEvents, *deadLetterMaxAttempts, *deadLetterReplayTimeout)
+	if nil != err {
+		return fmt.Errorf("failed to init dead letter queue: %v", err)
+	}
+	defaultQueue = q
+	return nil
+}
+
+func Enabled() bool {
+	return nil != defaultQueue
+}
+
+func ReplayInterval() time.Duration {
+	return *deadLetterReplayInterval
+}
+
+// Add persists ev into the default queue, the event is only logged if the
+// queue is disabled.
+func Add(sink string, ev *model.Event, reason error) {
+	if nil == defaultQueue {
+		fmt.Printf("event %s/%s to %s is lost: %s\n", ev.Namespace, ev.Name, sink, reason.Error())
+		return
+	}
+	if err := defaultQueue.Add(sink, ev, reason); nil != err {
+		fmt.Printf("failed to add dead letter of %s: %s\n", sink, err.Error())
+	}
+}
+
+func Replay(sink string, deliver DeliverFunc) (int, error) {
+	if nil == defaultQueue {
+		return 0, nil
+	}
+	return defaultQueue.Replay(sink, deliver)
+}
+
+func Counts() map[string]int {
+	if nil == defaultQueue {
+		return map[string]int{}
+	}
+	return defaultQueue.Counts()
+}
+
+func RejectedCounts() map[string]int {
+	if nil == defaultQueue {
+		return map[string]int{}
+	}
+	return defaultQueue.RejectedCounts()
+}
+
+func (q *Queue) path(sink string) string {
+	return filepath.Join(q.dir, sink+fileSuffix)
+}
+
+// Add persists ev, it is rejected right away if reason is permanent.
+func (q *Queue) Add(sink string, ev *model.Event, reason error) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	records := []Record{{
+		Time:   time.Now(),
+		Sink:   sink,
+		Reason: reason.Error(),
+		Event:  ev,
+	}}
+	if handler.IsPermanent(reason) {
+		return q.reject(sink, records)
+	}
+	return q.add(sink, records)
+}
+
+func (q *Queue) add(sink string, records []Record) error {
+	if q.maxEvents > 0 && q.counts[sink]+len(records) > q.maxEvents {
+		return fmt.Errorf("dead letter queue of %s is full", sink)
+	}
+	return appendRecords(q.path(sink), records, q.counts, sink)
+}
+
+// reject keeps records failed for good for inspection, they are never
+// replayed.
+func (q *Queue) reject(sink string, records []Record) error {
+	for _, r := range records {
+		fmt.Printf("event %s/%s to %s is rejected: %s\n", r.Event.Namespace, r.Event.Name, sink, r.Reason)
+	}
+	if q.maxEvents > 0 && q.rejected[sink]+len(records) > q.maxEvents {
+		return fmt.Errorf("rejected events of %s are full", sink)
+	}
+	return appendRecords(q.path(sink)+rejectedSuffix, records, q.rejected, sink)
+}
+
+func appendRecords(path string, records []Record, counts map[string]int, sink string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if nil != err {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); nil != err {
+			return err
+		}
+		counts[sink]++
+	}
+	return w.Flush()
+}
+
+// DeliverFunc writes ev to a sink and calls done once it is delivered or
+// failed to be.
+type DeliverFunc func(ev *model.Event, done func(err error))
+
+// Replay delivers the dead letters of sink in batches and waits for every
+// batch to be delivered, at most for the replay timeout of q. Failed events
+// are kept in order for the next try, unless they are rejected by the sink
+// or out of attempts. Once a batch times out, the rest is kept without
+// being replayed. The dead letters being replayed are kept on disk until
+// then, so a crash replays them again on the next start.
+func (q *Queue) Replay(sink string, deliver DeliverFunc) (int, error) {
+	q.lock.Lock()
+	path := q.path(sink)
+	replaying := path + replayingSuffix
+	if q.counts[sink] == 0 {
+		q.lock.Unlock()
+		return 0, nil
+	}
+	if err := os.Rename(path, replaying); nil != err {
+		q.lock.Unlock()
+		return 0, err
+	}
+	q.counts[sink] = 0
+	q.lock.Unlock()
+
+	records, err := readRecords(replaying)
+	if nil != err {
+		return 0, err
+	}
+	errs := make([]error, len(records))
+	for i := range errs {
+		errs[i] = errNotReplayed
+	}
+	for start := 0; start < len(records); start += replayBatch {
+		end := start + replayBatch
+		if end > len(records) {
+			end = len(records)
+		}
+		if !q.deliverBatch(records[start:end], errs[start:end], deliver) {
+			break
+		}
+	}
+
+	replayed := 0
+	var replayErr error
+	retries := make([]Record, 0)
+	rejected := make([]Record, 0)
+	for i, r := range records {
+		if nil == errs[i] {
+			replayed++
+			continue
+		}
+		if errs[i] == errNotReplayed {
+			retries = append(retries, r)
+			continue
+		}
+		replayErr = errs[i]
+		r.Attempts++
+		r.Reason = errs[i].Error()
+		if handler.IsPermanent(errs[i]) || (q.maxAttempts > 0 && r.Attempts >= q.maxAttempts) {
+			rejected = append(rejected, r)
+		} else {
+			retries = append(retries, r)
+		}
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(retries) > 0 {
+		// keep the order, put the rest back in front of newer dead letters
+		if err := q.prepend(sink, retries); nil != err {
+			return replayed, err
+		}
+	}
+	if len(rejected) > 0 {
+		if err := q.reject(sink, rejected); nil != err {
+			fmt.Printf("failed to reject dead letters of %s: %s\n", sink, err.Error())
+		}
+	}
+	if err := os.Remove(replaying); nil != err {
+		return replayed, err
+	}
+	return replayed, replayErr
+}
+
+// deliverBatch delivers records and stores the result of each one in errs.
+// It returns false if the replay timeout expires first, the records not
+// delivered by then are failed with errReplayTimeout and their late
+// results are ignored.
+func (q *Queue) deliverBatch(records []Record, errs []error, deliver DeliverFunc) bool {
+	var lock sync.Mutex
+	timedOut := false
+	pending := len(records)
+	delivered := make(chan struct{})
+	for i := range records {
+		i := i
+		deliver(records[i].Event, func(err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			if timedOut {
+				return
+			}
+			errs[i] = err
+			if pending--; pending == 0 {
+				close(delivered)
+			}
+		})
+	}
+
+	var timeoutCh <-chan time.Time
+	if q.replayTimeout > 0 {
+		timer := time.NewTimer(q.replayTimeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+	select {
+	case <-delivered:
+		return true
+	case <-timeoutCh:
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if pending == 0 {
+		return true
+	}
+	timedOut = true
+	for i := range errs {
+		if errs[i] == errNotReplayed {
+			errs[i] = errReplayTimeout
+		}
+	}
+	return false
+}
+
+func (q *Queue) prepend(sink string, records []Record) error {
+	newer, err := readRecords(q.path(sink))
+	if nil != err && !os.IsNotExist(err) {
+		return err
+	}
+	tmp := q.path(sink) + ".tmp"
+	os.Remove(tmp)
+	all := append(records, newer...)
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if nil != err {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range all {
+		if err := enc.Encode(r); nil != err {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); nil != err {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); nil != err {
+		return err
+	}
+	if err := os.Rename(tmp, q.path(sink)); nil != err {
+		return err
+	}
+	q.counts[sink] = len(all)
+	return nil
+}
+
+func (q *Queue) restore(replaying string) error {
+	records, err := readRecords(replaying)
+	if nil != err {
+		return err
+	}
+	sink := strings.TrimSuffix(filepath.Base(replaying), fileSuffix+replayingSuffix)
+	if err := q.prepend(sink, records); nil != err {
+		return err
+	}
+	return os.Remove(replaying)
+}
+
+func (q *Queue) Counts() map[string]int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return copyCounts(q.counts)
+}
+
+func (q *Queue) RejectedCounts() map[string]int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return copyCounts(q.rejected)
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for sink, n := range counts {
+		copied[sink] = n
+	}
+	return copied
+}
+
+func readRecords(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if nil != err {
+		return nil, err
+	}
+	defer f.Close()
+	records := make([]Record, 0)
+	dec := json.NewDecoder(f)
+	for {
+		var r Record
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if nil != err {
+			return records, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if nil != err {
+		return 0, err
+	}
+	defer f.Close()
+	n := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		n++
+	}
+	return n, scanner.Err()
+}
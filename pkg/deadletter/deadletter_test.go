@@ -0,0 +1,194 @@
+package deadletter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/handler"
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "luxun-deadletter")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := New(dir, 10, 0, 0)
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if err := q.Add("es", &model.Event{Name: name}, fmt.Errorf("queue blocked")); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	// the backend fails the second event
+	replayed := make([]string, 0)
+	n, err := q.Replay("es", func(ev *model.Event, done func(err error)) {
+		if ev.Name == "b" {
+			done(fmt.Errorf("unavailable"))
+			return
+		}
+		replayed = append(replayed, ev.Name)
+		done(nil)
+	})
+	if n != 2 || nil == err {
+		t.Fatalf("excepted 2 events replayed with an error, got %d, %v", n, err)
+	}
+
+	// reopening the queue keeps the failed one
+	q, err = New(dir, 10, 0, 0)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if count := q.Counts()["es"]; count != 1 {
+		t.Fatalf("excepted 1 dead letter, got %d", count)
+	}
+	n, err = q.Replay("es", func(ev *model.Event, done func(err error)) {
+		replayed = append(replayed, ev.Name)
+		done(nil)
+	})
+	if n != 1 || nil != err {
+		t.Fatalf("excepted 1 event replayed, got %d, %v", n, err)
+	}
+	if fmt.Sprint(replayed) != "[a c b]" {
+		t.Fatalf("excepted every event replayed once, got %v", replayed)
+	}
+	if count := q.Counts()["es"]; count != 0 {
+		t.Fatalf("excepted empty queue, got %d", count)
+	}
+}
+
+func TestReplayAsync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "luxun-deadletter")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := New(dir, 10, 0, 0)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err := q.Add("es", &model.Event{Name: "a"}, fmt.Errorf("queue blocked")); nil != err {
+		t.Fatal(err)
+	}
+
+	// the dead letters are kept on disk until the sink confirms delivery
+	delivered := make(chan struct{})
+	replayed := make(chan struct{})
+	var done func(err error)
+	go func() {
+		q.Replay("es", func(ev *model.Event, fn func(err error)) {
+			done = fn
+			close(delivered)
+		})
+		close(replayed)
+	}()
+	<-delivered
+	if _, err := os.Stat(q.path("es") + replayingSuffix); nil != err {
+		t.Fatalf("excepted dead letters kept while being delivered, got %v", err)
+	}
+	q2, err := New(dir, 10, 0, 0)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if count := q2.Counts()["es"]; count != 1 {
+		t.Fatalf("excepted unconfirmed dead letters restored after a crash, got %d", count)
+	}
+	done(nil)
+	<-replayed
+}
+
+func TestReplayAttempts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "luxun-deadletter")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := New(dir, 10, 2, 0)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err := q.Add("es", &model.Event{Name: "rejected"}, handler.Permanent(fmt.Errorf("mapper_parsing_exception"))); nil != err {
+		t.Fatal(err)
+	}
+	if err := q.Add("es", &model.Event{Name: "unavailable"}, fmt.Errorf("es_rejected_execution_exception")); nil != err {
+		t.Fatal(err)
+	}
+	if count := q.Counts()["es"]; count != 1 {
+		t.Fatalf("excepted permanent failures not to be replayed, got %d dead letters", count)
+	}
+
+	attempts := 0
+	for i := 0; i < 3; i++ {
+		q.Replay("es", func(ev *model.Event, done func(err error)) {
+			attempts++
+			done(fmt.Errorf("unavailable"))
+		})
+	}
+	if attempts != 2 {
+		t.Fatalf("excepted 2 attempts, got %d", attempts)
+	}
+	if count := q.Counts()["es"]; count != 0 {
+		t.Fatalf("excepted empty queue, got %d", count)
+	}
+	if count := q.RejectedCounts()["es"]; count != 2 {
+		t.Fatalf("excepted 2 rejected events, got %d", count)
+	}
+
+	// rejected events are counted again after restart
+	q, err = New(dir, 10, 2, 0)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if count := q.RejectedCounts()["es"]; count != 2 {
+		t.Fatalf("excepted 2 rejected events after restart, got %d", count)
+	}
+}
+
+func TestReplayTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "luxun-deadletter")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := New(dir, 10, 0, 10*time.Millisecond)
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := q.Add("es", &model.Event{Name: name}, fmt.Errorf("queue blocked")); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	// the sink holds b until it is flushed, which never happens
+	var held func(err error)
+	n, err := q.Replay("es", func(ev *model.Event, done func(err error)) {
+		if ev.Name == "b" {
+			held = done
+			return
+		}
+		done(nil)
+	})
+	if n != 1 || err != errReplayTimeout {
+		t.Fatalf("excepted 1 event replayed and a timeout, got %d, %v", n, err)
+	}
+	if count := q.Counts()["es"]; count != 1 {
+		t.Fatalf("excepted the held event to be kept, got %d dead letters", count)
+	}
+	// late results are ignored
+	held(nil)
+	if count := q.Counts()["es"]; count != 1 {
+		t.Fatalf("excepted late results to be ignored, got %d dead letters", count)
+	}
+}
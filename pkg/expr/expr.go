@@ -0,0 +1,323 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+// Expr is a compiled boolean expression over the fields of model.Event,
+// e.g.
+//
+//	type == "Warning" && namespace matches "prod-.*" && reason not in ["Pulled", "Created"]
+//
+// Supported operators are ==, !=, <, <=, >, >=, matches, not matches, in,
+// not in, &&, || and !. Labels and annotations are referred to as
+// labels.<key> and annotations.<key>.
+type Expr struct {
+	root node
+}
+
+type node interface {
+	eval(ev *model.Event) bool
+}
+
+func Compile(s string) (*Expr, error) {
+	tokens, err := lex(s)
+	if nil != err {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if nil != err {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", p.peek().text, p.peek().pos)
+	}
+	return &Expr{root: root}, nil
+}
+
+func (e *Expr) Match(ev *model.Event) bool {
+	return e.root.eval(ev)
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(ev *model.Event) bool { return n.left.eval(ev) && n.right.eval(ev) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(ev *model.Event) bool { return n.left.eval(ev) || n.right.eval(ev) }
+
+type notNode struct{ n node }
+
+func (n notNode) eval(ev *model.Event) bool { return !n.n.eval(ev) }
+
+type compareNode struct {
+	field  fieldFunc
+	op     string
+	values []string
+	re     *regexp.Regexp
+}
+
+func (n compareNode) eval(ev *model.Event) bool {
+	v := n.field(ev)
+	switch n.op {
+	case "==":
+		return v == n.values[0]
+	case "!=":
+		return v != n.values[0]
+	case "<", "<=", ">", ">=":
+		return compareOrdered(v, n.values[0], n.op)
+	case "matches":
+		return n.re.MatchString(v)
+	case "in":
+		for _, value := range n.values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// compareOrdered compares numerically if both sides are numbers, otherwise
+// lexically.
+func compareOrdered(a, b, op string) bool {
+	var c int
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if nil == errA && nil == errB {
+		switch {
+		case fa < fb:
+			c = -1
+		case fa > fb:
+			c = 1
+		}
+	} else {
+		c = strings.Compare(a, b)
+	}
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+type fieldFunc func(ev *model.Event) string
+
+var fields = map[string]fieldFunc{
+	"uid":        func(ev *model.Event) string { return ev.UID },
+	"name":       func(ev *model.Event) string { return ev.Name },
+	"namespace":  func(ev *model.Event) string { return ev.Namespace },
+	"kind":       func(ev *model.Event) string { return ev.Kind },
+	"objectName": func(ev *model.Event) string { return ev.ObjectName },
+	"reason":     func(ev *model.Event) string { return ev.Reason },
+	"message":    func(ev *model.Event) string { return ev.Message },
+	"count":      func(ev *model.Event) string { return strconv.Itoa(int(ev.Count)) },
+	"type":       func(ev *model.Event) string { return ev.Type },
+	"action":     func(ev *model.Event) string { return ev.Action },
+	"env":        func(ev *model.Event) string { return ev.Env },
+	"podStatus":  func(ev *model.Event) string { return ev.PodStatus },
+	"status":     func(ev *model.Event) string { return ev.Status },
+}
+
+func lookupField(name string) (fieldFunc, error) {
+	if fn, ok := fields[name]; ok {
+		return fn, nil
+	}
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) == 2 && parts[1] != "" {
+		key := parts[1]
+		switch parts[0] {
+		case "labels":
+			return func(ev *model.Event) string { return lookupKV(ev.Labels, key) }, nil
+		case "annotations":
+			return func(ev *model.Event) string { return lookupKV(ev.Annotations, key) }, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown field %q", name)
+}
+
+func lookupKV(kvs map[int]model.KVObject, key string) string {
+	for _, kv := range kvs {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return ""
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	t := p.next()
+	if t.kind != kind || (text != "" && t.text != text) {
+		return fmt.Errorf("expected %q at %d, got %q", text, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if nil != err {
+		return nil, err
+	}
+	for p.peek().kind == tokenOp && p.peek().text == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if nil != err {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if nil != err {
+		return nil, err
+	}
+	for p.peek().kind == tokenOp && p.peek().text == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if nil != err {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if t.kind == tokenOp && t.text == "!" {
+		p.next()
+		n, err := p.parseUnary()
+		if nil != err {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	if t.kind == tokenOp && t.text == "(" {
+		p.next()
+		n, err := p.parseOr()
+		if nil != err {
+			return nil, err
+		}
+		if err := p.expect(tokenOp, ")"); nil != err {
+			return nil, err
+		}
+		return n, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		return nil, fmt.Errorf("expected field at %d, got %q", t.pos, t.text)
+	}
+	field, err := lookupField(t.text)
+	if nil != err {
+		return nil, err
+	}
+
+	negate := false
+	op := p.next()
+	if op.kind == tokenIdent && op.text == "not" {
+		negate = true
+		op = p.next()
+		if op.kind != tokenIdent || (op.text != "in" && op.text != "matches") {
+			return nil, fmt.Errorf("expected in or matches after not at %d", op.pos)
+		}
+	}
+
+	n := compareNode{field: field}
+	switch {
+	case op.kind == tokenOp && (op.text == "==" || op.text == "!=" || op.text == "<" || op.text == "<=" || op.text == ">" || op.text == ">="):
+		v, err := p.parseValue()
+		if nil != err {
+			return nil, err
+		}
+		n.op, n.values = op.text, []string{v}
+	case op.kind == tokenIdent && op.text == "matches":
+		v, err := p.parseValue()
+		if nil != err {
+			return nil, err
+		}
+		if n.re, err = regexp.Compile("^(?:" + v + ")$"); nil != err {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", v, err)
+		}
+		n.op = "matches"
+	case op.kind == tokenIdent && op.text == "in":
+		if n.values, err = p.parseList(); nil != err {
+			return nil, err
+		}
+		n.op = "in"
+	default:
+		return nil, fmt.Errorf("expected operator at %d, got %q", op.pos, op.text)
+	}
+	if negate {
+		return notNode{n}, nil
+	}
+	return n, nil
+}
+
+func (p *parser) parseValue() (string, error) {
+	t := p.next()
+	if t.kind != tokenString && t.kind != tokenNumber {
+		return "", fmt.Errorf("expected value at %d, got %q", t.pos, t.text)
+	}
+	return t.text, nil
+}
+
+func (p *parser) parseList() ([]string, error) {
+	if err := p.expect(tokenOp, "["); nil != err {
+		return nil, err
+	}
+	values := make([]string, 0)
+	if p.peek().kind == tokenOp && p.peek().text == "]" {
+		p.next()
+		return values, nil
+	}
+	for {
+		v, err := p.parseValue()
+		if nil != err {
+			return nil, err
+		}
+		values = append(values, v)
+		t := p.next()
+		if t.kind == tokenOp && t.text == "]" {
+			return values, nil
+		}
+		if t.kind != tokenOp || t.text != "," {
+			return nil, fmt.Errorf("expected , or ] at %d, got %q", t.pos, t.text)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestExpr(t *testing.T) {
+	ev := &model.Event{
+		Namespace: "prod-web",
+		Type:      "Warning",
+		Reason:    "BackOff",
+		Count:     12,
+		Labels:    map[int]model.KVObject{0: {Key: "app.kubernetes.io/name", Value: "web"}},
+	}
+	cases := []struct {
+		expr     string
+		excepted bool
+	}{
+		{`type == "Warning" && namespace matches "prod-.*" && reason not in ["Pulled", "Created"]`, true},
+		{`type == "Warning" && reason in ["Pulled", "Created"]`, false},
+		{`namespace matches "prod"`, false},
+		{`namespace not matches "kube-.*" && count >= 10`, true},
+		{`count < 10 || labels.app.kubernetes.io/name == "web"`, true},
+		{`!(type == "Normal" || kind == "Pod")`, true},
+		{`annotations.owner != ""`, false},
+	}
+	for _, c := range cases {
+		e, err := Compile(c.expr)
+		if nil != err {
+			t.Fatalf("failed to compile %s: %v", c.expr, err)
+		}
+		if got := e.Match(ev); got != c.excepted {
+			t.Fatalf("%s: excepted %v, got %v", c.expr, c.excepted, got)
+		}
+	}
+
+	for _, invalid := range []string{`type ==`, `unknown == "a"`, `type in "a"`, `(type == "a"`, `type == "a" extra`} {
+		if _, err := Compile(invalid); nil == err {
+			t.Fatalf("excepted error compiling %s", invalid)
+		}
+	}
+}
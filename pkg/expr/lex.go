@@ -0,0 +1,94 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func lex(s string) ([]token, error) {
+	tokens := make([]token, 0)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(s) && s[end] != c {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			text := s[i+1 : end]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(s[i : end+1])
+				if nil != err {
+					return nil, fmt.Errorf("invalid string at %d: %v", i, err)
+				}
+				text = unquoted
+			}
+			tokens = append(tokens, token{tokenString, text, i})
+			i = end + 1
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			end := i + 1
+			for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{tokenNumber, s[i:end], i})
+			i = end
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(s) && isIdentChar(s[end]) {
+				end++
+			}
+			tokens = append(tokens, token{tokenIdent, s[i:end], i})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+			tokens = append(tokens, token{tokenOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(s)}), nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// label keys such as app.kubernetes.io/name are part of the identifier
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '.' || c == '/' || c == '-'
+}
@@ -0,0 +1,145 @@
+package elasticsearch
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	esUsername           = flag.String("es-username", "", "username of basic authentication, falls back to $ES_USERNAME")
+	esUsernameFile       = flag.String("es-username-file", "", "file containing the username of basic authentication")
+	esPassword           = flag.String("es-password", "", "password of basic authentication, falls back to $ES_PASSWORD")
+	esPasswordFile       = flag.String("es-password-file", "", "file containing the password of basic authentication")
+	esApiKey             = flag.String("es-api-key", "", "API key in the form of id:key or its base64 encoding, falls back to $ES_API_KEY")
+	esApiKeyFile         = flag.String("es-api-key-file", "", "file containing the API key")
+	esCAFile             = flag.String("es-ca-file", "", "PEM encoded CA bundle used to verify elasticsearch")
+	esCertFile           = flag.String("es-cert-file", "", "PEM encoded client certificate")
+	esKeyFile            = flag.String("es-key-file", "", "PEM encoded client key")
+	esInsecureSkipVerify = flag.Bool("es-insecure-skip-verify", false, "skip verifying the certificate of elasticsearch, insecure")
+)
+
+type AuthConfig struct {
+	Username           string
+	Password           string
+	ApiKey             string
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+func authConfigFromFlags() (AuthConfig, error) {
+	var err error
+	config := AuthConfig{
+		CAFile:             *esCAFile,
+		CertFile:           *esCertFile,
+		KeyFile:            *esKeyFile,
+		InsecureSkipVerify: *esInsecureSkipVerify,
+	}
+	if config.Username, err = resolveSecret(*esUsername, *esUsernameFile, "ES_USERNAME"); nil != err {
+		return config, err
+	}
+	if config.Password, err = resolveSecret(*esPassword, *esPasswordFile, "ES_PASSWORD"); nil != err {
+		return config, err
+	}
+	if config.ApiKey, err = resolveSecret(*esApiKey, *esApiKeyFile, "ES_API_KEY"); nil != err {
+		return config, err
+	}
+	return config, nil
+}
+
+// resolveSecret returns the value of the flag, or the content of the file,
+// or the environment variable, whichever is found first.
+func resolveSecret(value, file, env string) (string, error) {
+	if value != "" {
+		return value, nil
+	}
+	if file != "" {
+		content, err := ioutil.ReadFile(file)
+		if nil != err {
+			return "", fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		return strings.TrimSpace(string(content)), nil
+	}
+	return os.Getenv(env), nil
+}
+
+func (c AuthConfig) Validate() error {
+	if c.Username != "" && c.ApiKey != "" {
+		return fmt.Errorf("basic authentication and API key are mutually exclusive")
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("es-cert-file and es-key-file must be set together")
+	}
+	return nil
+}
+
+func newHttpClient(config Config) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.Auth.InsecureSkipVerify,
+	}
+	if config.Auth.CAFile != "" {
+		ca, err := ioutil.ReadFile(config.Auth.CAFile)
+		if nil != err {
+			return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate was found in %s", config.Auth.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.Auth.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.Auth.CertFile, config.Auth.KeyFile)
+		if nil != err {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+	if config.Auth.ApiKey != "" {
+		transport = &apiKeyTransport{
+			apiKey: encodeApiKey(config.Auth.ApiKey),
+			next:   transport,
+		}
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   config.RequestTimeout,
+	}, nil
+}
+
+// encodeApiKey accepts both the raw id:key pair and its base64 encoding.
+func encodeApiKey(apiKey string) string {
+	if strings.Contains(apiKey, ":") {
+		return base64.StdEncoding.EncodeToString([]byte(apiKey))
+	}
+	return apiKey
+}
+
+type apiKeyTransport struct {
+	apiKey string
+	next   http.RoundTripper
+}
+
+func (t *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Authorization", "ApiKey "+t.apiKey)
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,131 @@
+package elasticsearch
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBulkActions         = 1000
+	defaultBulkSize            = 5 << 20
+	defaultBulkFlushInterval   = 10 * time.Second
+	defaultBulkWorkers         = 3
+	defaultMaxRetries          = 3
+	defaultHealthcheck         = true
+	defaultHealthcheckInterval = 60 * time.Second
+	defaultSniff               = true
+	defaultRequestTimeout      = 30 * time.Second
+	defaultQueueSize           = 1000
+)
+
+var (
+	defaultIndex          = flag.String("es-index", "kubernetes-events", "index name of kubernetes events")
+	defaultEsUrls         = flag.String("es-urls", "", "comma separated endpoints of Events backend elasticsearch")
+	esBulkActions         = flag.Int("es-bulk-actions", defaultBulkActions, "number of requests that triggers a bulk commit, -1 disables it")
+	esBulkSize            = flag.Int("es-bulk-size", defaultBulkSize, "size in bytes of requests that triggers a bulk commit, -1 disables it")
+	esBulkFlushInterval   = flag.Duration("es-bulk-flush-interval", defaultBulkFlushInterval, "interval of committing pending bulk requests, 0 disables it")
+	esBulkWorkers         = flag.Int("es-bulk-workers", defaultBulkWorkers, "number of workers committing bulk requests")
+	esMaxRetries          = flag.Int("es-max-retries", defaultMaxRetries, "max number of retries of a failed request")
+	esHealthcheck         = flag.Bool("es-healthcheck", defaultHealthcheck, "periodically check the health of elasticsearch nodes")
+	esHealthcheckInterval = flag.Duration("es-healthcheck-interval", defaultHealthcheckInterval, "interval between health checks")
+	esSniff               = flag.Bool("es-sniff", defaultSniff, "sniff the cluster for the list of nodes")
+	esRequestTimeout      = flag.Duration("es-request-timeout", defaultRequestTimeout, "timeout of a single request to elasticsearch")
+	esQueueSize           = flag.Int("es-queue-size", defaultQueueSize, "size of the queue in front of the bulk processor")
+)
+
+type Config struct {
+	Index               string
+	IndexPattern        string
+	IndexRules          []IndexRule
+	Urls                []string
+	BulkActions         int
+	BulkSize            int
+	BulkFlushInterval   time.Duration
+	BulkWorkers         int
+	MaxRetries          int
+	Healthcheck         bool
+	HealthcheckInterval time.Duration
+	Sniff               bool
+	RequestTimeout      time.Duration
+	QueueSize           int
+	DocumentId          string
+	Version             string
+	Auth                AuthConfig
+	Template            TemplateConfig
+}
+
+func configFromFlags() (Config, error) {
+	auth, err := authConfigFromFlags()
+	if nil != err {
+		return Config{}, err
+	}
+	rules, err := loadIndexRules(*esIndexRules)
+	if nil != err {
+		return Config{}, err
+	}
+	urls := make([]string, 0)
+	for _, url := range strings.Split(*defaultEsUrls, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return Config{
+		Index:               *defaultIndex,
+		IndexPattern:        *esIndexPattern,
+		IndexRules:          rules,
+		Urls:                urls,
+		BulkActions:         *esBulkActions,
+		BulkSize:            *esBulkSize,
+		BulkFlushInterval:   *esBulkFlushInterval,
+		BulkWorkers:         *esBulkWorkers,
+		MaxRetries:          *esMaxRetries,
+		Healthcheck:         *esHealthcheck,
+		HealthcheckInterval: *esHealthcheckInterval,
+		Sniff:               *esSniff,
+		RequestTimeout:      *esRequestTimeout,
+		QueueSize:           *esQueueSize,
+		DocumentId:          *esDocumentId,
+		Version:             *esVersion,
+		Auth:                auth,
+		Template:            templateConfigFromFlags(),
+	}, nil
+}
+
+func (c Config) Validate() error {
+	switch {
+	case c.Index == "":
+		return fmt.Errorf("es-index must not be empty")
+	case len(c.Urls) == 0:
+		return fmt.Errorf("es-urls must not be empty")
+	case c.BulkActions == 0 || c.BulkActions < -1:
+		return fmt.Errorf("es-bulk-actions must be positive or -1, got %d", c.BulkActions)
+	case c.BulkSize == 0 || c.BulkSize < -1:
+		return fmt.Errorf("es-bulk-size must be positive or -1, got %d", c.BulkSize)
+	case c.BulkActions == -1 && c.BulkSize == -1 && c.BulkFlushInterval <= 0:
+		return fmt.Errorf("at least one of es-bulk-actions, es-bulk-size and es-bulk-flush-interval must be enabled")
+	case c.BulkFlushInterval < 0:
+		return fmt.Errorf("es-bulk-flush-interval must not be negative, got %v", c.BulkFlushInterval)
+	case c.BulkWorkers <= 0:
+		return fmt.Errorf("es-bulk-workers must be positive, got %d", c.BulkWorkers)
+	case c.MaxRetries < 0:
+		return fmt.Errorf("es-max-retries must not be negative, got %d", c.MaxRetries)
+	case c.Healthcheck && c.HealthcheckInterval <= 0:
+		return fmt.Errorf("es-healthcheck-interval must be positive, got %v", c.HealthcheckInterval)
+	case c.RequestTimeout < 0:
+		return fmt.Errorf("es-request-timeout must not be negative, got %v", c.RequestTimeout)
+	case c.QueueSize <= 0:
+		return fmt.Errorf("es-queue-size must be positive, got %d", c.QueueSize)
+	}
+	if err := validateDocumentId(c.DocumentId); nil != err {
+		return err
+	}
+	if err := c.Template.Validate(); nil != err {
+		return err
+	}
+	if c.Template.Rollover && (c.IndexPattern != defaultIndexPattern || len(c.IndexRules) > 0) {
+		return fmt.Errorf("es-rollover can not be combined with es-index-pattern or es-index-rules")
+	}
+	return c.Auth.Validate()
+}
@@ -2,78 +2,162 @@ package elasticsearch
 
 import (
 	"context"
-	"flag"
 	"fmt"
+	"net/http"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/jojohappy/luxun/pkg/handler"
+	"github.com/jojohappy/luxun/pkg/model"
 	"github.com/jojohappy/luxun/pkg/util"
 
 	elastic "gopkg.in/olivere/elastic.v5"
 )
 
-const (
-	defaultBulkAction        = 1000
-	defaultBulkSize          = 3
-	defaultBulkFlushInterval = 10 * time.Second
-	defaultMaxRetries        = 3
-	defaultBulkWorker        = 3
-	defaultHealthcheck       = true
-)
-
-var (
-	defaultIndex  = flag.String("es-index", "kubernetes-events", "index name of kubernetes events")
-	defaultEsUrls = flag.String("es-urls", "", "Endpoints of Events backend elasticsearch")
-)
-
 type ElasticClient struct {
 	Client        *elastic.Client
 	bulkProcessor *elastic.BulkProcessor
 	index         string
-	healthcheck   bool
-	maxRetries    int
-	urls          []string
-	q             chan interface{}
+	router        *indexRouter
+	docType       string
+	config        Config
+	q             chan bulkItem
 	shutdownCh    chan struct{}
+	doneCh        chan struct{}
+
+	ackLock sync.Mutex
+	acks    map[elastic.BulkableRequest]func(err error)
 }
 
-var (
-	esClient *ElasticClient
-	once     sync.Once
-)
+type bulkItem struct {
+	doc  interface{}
+	done func(err error)
+}
+
+func init() {
+	handler.RegisterSink("elasticsearch", NewSink)
+}
+
+func NewSink() (handler.Sink, error) {
+	config, err := configFromFlags()
+	if nil != err {
+		return nil, err
+	}
+	es, err := NewElasticStorage(config)
+	if nil != err {
+		return nil, err
+	}
+	es.Run()
+	return es, nil
+}
 
-func NewElasticStorage(index string, urls ...string) (*ElasticClient, error) {
-	client, err := elastic.NewClient(
-		elastic.SetURL(urls...),
-		elastic.SetHealthcheck(defaultHealthcheck),
-		elastic.SetMaxRetries(defaultMaxRetries))
+func NewElasticStorage(config Config) (*ElasticClient, error) {
+	if err := config.Validate(); nil != err {
+		return nil, err
+	}
+	router, err := newIndexRouter(config.Index, config.IndexPattern, config.DocumentId, config.IndexRules)
 	if nil != err {
 		return nil, err
 	}
 
-	bp, err := client.BulkProcessor().
+	httpClient, err := newHttpClient(config)
+	if nil != err {
+		return nil, err
+	}
+	options := []elastic.ClientOptionFunc{
+		elastic.SetURL(config.Urls...),
+		elastic.SetSniff(config.Sniff),
+		elastic.SetHealthcheck(config.Healthcheck),
+		elastic.SetHealthcheckInterval(config.HealthcheckInterval),
+		elastic.SetMaxRetries(config.MaxRetries),
+		elastic.SetHttpClient(httpClient),
+	}
+	if strings.HasPrefix(config.Urls[0], "https://") {
+		options = append(options, elastic.SetScheme("https"))
+	}
+	if config.Auth.Username != "" {
+		options = append(options, elastic.SetBasicAuth(config.Auth.Username, config.Auth.Password))
+	}
+	client, err := elastic.NewClient(options...)
+	if nil != err {
+		return nil, err
+	}
+
+	version, err := resolveVersion(client, config.Version)
+	if nil != err {
+		return nil, err
+	}
+	fmt.Printf("writing events to %s\n", version)
+	if config.Template.Install {
+		if err := installTemplate(client, version, config.Index, config.Template); nil != err {
+			return nil, err
+		}
+	}
+	// mapping types are removed since elasticsearch 7
+	docType := config.Index
+	if version.typeless() {
+		docType = ""
+	}
+
+	es := &ElasticClient{
+		Client:     client,
+		index:      config.Index,
+		router:     router,
+		docType:    docType,
+		config:     config,
+		q:          make(chan bulkItem, config.QueueSize),
+		shutdownCh: make(chan struct{}),
+		doneCh:     make(chan struct{}),
+		acks:       make(map[elastic.BulkableRequest]func(err error)),
+	}
+	es.bulkProcessor, err = client.BulkProcessor().
 		Name("Luxun-Elastic").
-		Workers(defaultBulkWorker).
-		BulkActions(defaultBulkAction).
-		BulkSize(defaultBulkSize).
-		FlushInterval(defaultBulkFlushInterval).
+		Workers(config.BulkWorkers).
+		BulkActions(config.BulkActions).
+		BulkSize(config.BulkSize).
+		FlushInterval(config.BulkFlushInterval).
 		Stats(true).
+		After(es.afterCommit).
 		Do(context.Background())
 	if nil != err {
 		return nil, err
 	}
+	return es, nil
+}
+
+// afterCommit reports the result of every request of a commit. Items
+// rejected with 429 or a server error may be indexed later, the others are
+// rejected for good.
+func (es *ElasticClient) afterCommit(_ int64, requests []elastic.BulkableRequest, response *elastic.BulkResponse, err error) {
+	for i, req := range requests {
+		reason := err
+		if nil == reason && nil != response && i < len(response.Items) {
+			for _, item := range response.Items[i] {
+				if nil == item.Error {
+					continue
+				}
+				reason = fmt.Errorf("%s: %s", item.Error.Type, item.Error.Reason)
+				if item.Status != http.StatusTooManyRequests && item.Status < 500 {
+					reason = handler.Permanent(reason)
+				}
+			}
+		}
+		if done := es.ack(req); nil != done {
+			done(reason)
+		} else if nil != reason {
+			fmt.Printf("failed to index %s: %s\n", req.String(), reason.Error())
+		}
+	}
+}
 
-	return &ElasticClient{
-		Client:        client,
-		bulkProcessor: bp,
-		index:         index,
-		healthcheck:   defaultHealthcheck,
-		maxRetries:    defaultMaxRetries,
-		urls:          urls,
-		q:             make(chan interface{}, defaultBulkAction),
-		shutdownCh:    make(chan struct{}),
-	}, nil
+func (es *ElasticClient) ack(req elastic.BulkableRequest) func(err error) {
+	es.ackLock.Lock()
+	defer es.ackLock.Unlock()
+	done := es.acks[req]
+	delete(es.acks, req)
+	return done
 }
 
 func (es *ElasticClient) Run() {
@@ -82,44 +166,119 @@ func (es *ElasticClient) Run() {
 	}()
 }
 
+// Shutdown adds the queued events to the bulk processor and commits its
+// pending requests, requests never committed are reported as failed.
 func (es *ElasticClient) Shutdown() {
 	close(es.shutdownCh)
-	es.bulkProcessor.Close()
+	<-es.doneCh
+	if err := es.bulkProcessor.Close(); nil != err {
+		fmt.Printf("failed to flush elasticsearch bulk processor: %s\n", err.Error())
+	}
+	es.ackLock.Lock()
+	acks := es.acks
+	es.acks = make(map[elastic.BulkableRequest]func(err error))
+	es.ackLock.Unlock()
+	for _, done := range acks {
+		done(fmt.Errorf("elasticsearch sink is stopped"))
+	}
+}
+
+func (es *ElasticClient) Name() string {
+	return "elasticsearch"
+}
+
+func (es *ElasticClient) Write(ev *model.Event) error {
+	return es.Bulk(ev)
+}
+
+// WriteAck queues ev, done is called once the bulk request of ev is
+// committed.
+func (es *ElasticClient) WriteAck(ev *model.Event, done func(err error)) error {
+	select {
+	case es.q <- bulkItem{doc: ev, done: done}:
+		return nil
+	default:
+		return fmt.Errorf("elasticsearch queue blocked")
+	}
+}
+
+func (es *ElasticClient) Stop() {
+	es.Shutdown()
 }
 
 func (es *ElasticClient) runQueueRoutine() {
-	defer close(es.q)
-	var t time.Time
+	defer close(es.doneCh)
 	for {
 		select {
-		case ev, ok := <-es.q:
-			if ok {
-				v := util.ParseValuePointers(reflect.ValueOf(ev))
-				if v.Kind() == reflect.Struct {
-					tv := v.FieldByName("Time")
-					if tv.IsValid() {
-						t = tv.Interface().(time.Time)
-						es.bulkProcessor.Add(elastic.NewBulkIndexRequest().Index(fmt.Sprintf("%s-%s", es.index, t.Format("2006.01.02"))).Type(es.index).Doc(ev))
-					} else {
-						fmt.Printf("no time filed was found in %v\n", ev)
-						es.bulkProcessor.Add(elastic.NewBulkIndexRequest().Index(es.index).Type(es.index).Doc(ev))
-					}
-				} else {
-					fmt.Printf("kind of %v is not struct\n", ev)
+		case item := <-es.q:
+			es.add(item)
+		case <-es.shutdownCh:
+			for {
+				select {
+				case item := <-es.q:
+					es.add(item)
+				default:
+					return
 				}
 			}
-		case <-es.shutdownCh:
-			return
 		}
 	}
 }
 
+func (es *ElasticClient) add(item bulkItem) {
+	ev := item.doc
+	v := util.ParseValuePointers(reflect.ValueOf(ev))
+	if v.Kind() != reflect.Struct {
+		es.fail(item, fmt.Errorf("kind of %v is not struct", ev))
+		return
+	}
+	index, err := es.indexOf(ev, v)
+	if nil != err {
+		es.fail(item, fmt.Errorf("failed to resolve index of %v: %v", ev, err))
+		return
+	}
+	req := elastic.NewBulkIndexRequest().Index(index).Type(es.docType).Doc(ev)
+	if id := documentId(ev, es.config.DocumentId); id != "" {
+		req.Id(id)
+	}
+	if nil != item.done {
+		es.ackLock.Lock()
+		es.acks[req] = item.done
+		es.ackLock.Unlock()
+	}
+	es.bulkProcessor.Add(req)
+}
+
+func (es *ElasticClient) fail(item bulkItem, err error) {
+	if nil == item.done {
+		fmt.Println(err.Error())
+		return
+	}
+	item.done(handler.Permanent(err))
+}
+
+func (es *ElasticClient) indexOf(ev interface{}, v reflect.Value) (string, error) {
+	if es.config.Template.Rollover {
+		return es.index, nil
+	}
+	if e, ok := ev.(*model.Event); ok {
+		return es.router.Index(e)
+	}
+	tv := v.FieldByName("Time")
+	if !tv.IsValid() {
+		fmt.Printf("no time filed was found in %v\n", ev)
+		return es.index, nil
+	}
+	t := tv.Interface().(time.Time)
+	return fmt.Sprintf("%s-%s", es.index, t.Format("2006.01.02")), nil
+}
+
 func (es *ElasticClient) Bulk(v ...interface{}) error {
 	var err error
 	func() {
 		for _, ev := range v {
 			select {
-			case es.q <- ev:
+			case es.q <- bulkItem{doc: ev}:
 			default:
 				err = fmt.Errorf("elasticsearch queue blocked")
 			}
@@ -130,16 +289,3 @@ func (es *ElasticClient) Bulk(v ...interface{}) error {
 	}
 	return nil
 }
-
-func ElasticSource() *ElasticClient {
-	flag.Parse()
-	var err error
-	once.Do(func() {
-		esClient, err = NewElasticStorage(*defaultIndex, *defaultEsUrls)
-		if nil != err {
-			panic(err)
-		}
-		esClient.Run()
-	})
-	return esClient
-}
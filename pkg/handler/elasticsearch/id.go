@@ -0,0 +1,87 @@
+package elasticsearch
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+const (
+	// DocumentIdRandom lets elasticsearch generate the id, every update of
+	// an event is indexed as a new document.
+	DocumentIdRandom = "random"
+	// DocumentIdUID uses the UID of Event records, updates of an event
+	// upsert the same document. Events synthesized from the state of an
+	// object share its UID, so their id also has the reason and time.
+	DocumentIdUID = "uid"
+	// DocumentIdName uses namespace/name of the object, for objects that are
+	// recreated with the same name. A recreated object is routed by its new
+	// creation time, so it only upserts the same document if the index
+	// pattern maps both creations to the same index.
+	DocumentIdName = "name"
+	// DocumentIdOccurrence uses the UID and the count of an Event record,
+	// every occurrence is kept once even if it is delivered more than once.
+	// Synthesized events get generated ids.
+	DocumentIdOccurrence = "occurrence"
+)
+
+var esDocumentId = flag.String("es-document-id", DocumentIdRandom, "how document ids are derived, one of random, uid, name or occurrence")
+
+func validateDocumentId(mode string) error {
+	switch mode {
+	case DocumentIdRandom, DocumentIdUID, DocumentIdName, DocumentIdOccurrence:
+		return nil
+	}
+	return fmt.Errorf("unknown es-document-id %q", mode)
+}
+
+// documentId returns the id of the document of v, an empty id means the id
+// is generated by elasticsearch.
+func documentId(v interface{}, mode string) string {
+	ev, ok := v.(*model.Event)
+	if !ok {
+		return ""
+	}
+	switch mode {
+	case DocumentIdUID:
+		if ev.UID == "" || ev.Record {
+			return ev.UID
+		}
+		return fmt.Sprintf("%s-%s-%d", ev.UID, ev.Reason, ev.Time.UnixNano())
+	case DocumentIdName:
+		if ev.Name == "" {
+			return ""
+		}
+		return ev.Namespace + "/" + ev.Name
+	case DocumentIdOccurrence:
+		if ev.UID == "" || ev.Count == 0 || !ev.Record {
+			return ""
+		}
+		return fmt.Sprintf("%s-%d", ev.UID, ev.Count)
+	}
+	return ""
+}
+
+// indexTime is the time an event is routed to an index by. Documents
+// updated under a stable id must stay in one index, so they are routed by
+// the creation of the object rather than the time an update is processed.
+// The time of an occurrence does not change when it is delivered again.
+func indexTime(ev *model.Event, mode string) time.Time {
+	switch mode {
+	case DocumentIdRandom, DocumentIdOccurrence:
+		return ev.Time
+	case DocumentIdUID:
+		if !ev.Record {
+			return ev.Time
+		}
+	}
+	if !ev.CreationTimestamp.IsZero() {
+		return ev.CreationTimestamp
+	}
+	if !ev.FirstTimestamp.IsZero() {
+		return ev.FirstTimestamp
+	}
+	return ev.Time
+}
@@ -0,0 +1,74 @@
+package elasticsearch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestDocumentId(t *testing.T) {
+	ev := &model.Event{UID: "1234", Namespace: "default", Name: "web.15", Count: 3, Record: true}
+	// events of an object share its UID
+	synthesized := &model.Event{UID: "1234", Reason: "RolloutCompleted", Time: time.Unix(1530486000, 0)}
+	cases := []struct {
+		mode     string
+		v        interface{}
+		excepted string
+	}{
+		{DocumentIdRandom, ev, ""},
+		{DocumentIdUID, ev, "1234"},
+		{DocumentIdUID, synthesized, "1234-RolloutCompleted-1530486000000000000"},
+		{DocumentIdName, ev, "default/web.15"},
+		{DocumentIdOccurrence, ev, "1234-3"},
+		{DocumentIdUID, &model.Event{}, ""},
+		{DocumentIdName, &model.Event{Namespace: "default"}, ""},
+		{DocumentIdOccurrence, &model.Event{UID: "1234"}, ""},
+		{DocumentIdOccurrence, &model.Event{UID: "1234", Count: 1}, ""},
+		{DocumentIdUID, struct{ Time time.Time }{}, ""},
+	}
+	for _, c := range cases {
+		if id := documentId(c.v, c.mode); id != c.excepted {
+			t.Fatalf("excepted id %q in %s mode, got %q", c.excepted, c.mode, id)
+		}
+	}
+}
+
+func TestIndexOfUpdates(t *testing.T) {
+	created := time.Date(2018, 7, 1, 23, 0, 0, 0, time.UTC)
+	first := &model.Event{Time: created, CreationTimestamp: created, UID: "1234", Count: 1, Record: true}
+	// the count is bumped on the next day
+	bumped := *first
+	bumped.Time = created.Add(2 * time.Hour)
+	bumped.Count = 2
+
+	cases := []struct {
+		mode     string
+		ev       *model.Event
+		excepted string
+	}{
+		{DocumentIdUID, first, "kubernetes-events-2018.07.01"},
+		{DocumentIdUID, &bumped, "kubernetes-events-2018.07.01"},
+		{DocumentIdName, first, "kubernetes-events-2018.07.01"},
+		{DocumentIdName, &bumped, "kubernetes-events-2018.07.01"},
+		// every occurrence has its own id, delivering it again keeps its time
+		{DocumentIdOccurrence, first, "kubernetes-events-2018.07.01"},
+		{DocumentIdOccurrence, &bumped, "kubernetes-events-2018.07.02"},
+		{DocumentIdRandom, &bumped, "kubernetes-events-2018.07.02"},
+		// synthesized events are never updated
+		{DocumentIdUID, &model.Event{Time: bumped.Time, CreationTimestamp: created, UID: "1234"}, "kubernetes-events-2018.07.02"},
+	}
+	for _, c := range cases {
+		router, err := newIndexRouter("kubernetes-events", defaultIndexPattern, c.mode, nil)
+		if nil != err {
+			t.Fatal(err)
+		}
+		index, err := router.Index(c.ev)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if index != c.excepted {
+			t.Fatalf("excepted %s in %s mode, got %s", c.excepted, c.mode, index)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+const defaultIndexPattern = `{{.Index}}-{{day .Time}}`
+
+var (
+	esIndexPattern = flag.String("es-index-pattern", defaultIndexPattern, "Go template of index names, e.g. {{.Index}}-{{.Namespace}}-{{month .Time}}")
+	esIndexRules   = flag.String("es-index-rules", "", "path to a YAML file of rules routing matching events to their own index pattern")
+)
+
+// IndexRule routes events matching all of its conditions to Pattern. Every
+// condition is a regular expression that must match the whole field.
+type IndexRule struct {
+	Namespace string `yaml:"namespace"`
+	Kind      string `yaml:"kind"`
+	Type      string `yaml:"type"`
+	Reason    string `yaml:"reason"`
+	Env       string `yaml:"env"`
+	Pattern   string `yaml:"pattern"`
+}
+
+type indexContext struct {
+	*model.Event
+	Index string
+	// Time shadows the time of the event, see indexTime.
+	Time time.Time
+}
+
+type indexRoute struct {
+	matchers map[string]*regexp.Regexp
+	tmpl     *template.Template
+}
+
+type indexRouter struct {
+	index      string
+	documentId string
+	routes     []indexRoute
+	tmpl       *template.Template
+}
+
+var indexFuncs = template.FuncMap{
+	"day": func(t time.Time) string {
+		return t.Format("2006.01.02")
+	},
+	"week": func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d.w%02d", year, week)
+	},
+	"month": func(t time.Time) string {
+		return t.Format("2006.01")
+	},
+	"year": func(t time.Time) string {
+		return t.Format("2006")
+	},
+}
+
+func loadIndexRules(path string) ([]IndexRule, error) {
+	if path == "" {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, fmt.Errorf("failed to read index rules: %v", err)
+	}
+	var rules []IndexRule
+	if err := yaml.Unmarshal(content, &rules); nil != err {
+		return nil, fmt.Errorf("failed to parse index rules: %v", err)
+	}
+	return rules, nil
+}
+
+func newIndexRouter(index, pattern, documentId string, rules []IndexRule) (*indexRouter, error) {
+	tmpl, err := parseIndexPattern(pattern)
+	if nil != err {
+		return nil, err
+	}
+	router := &indexRouter{
+		index:      index,
+		documentId: documentId,
+		tmpl:       tmpl,
+	}
+	for i, rule := range rules {
+		route := indexRoute{matchers: make(map[string]*regexp.Regexp)}
+		conditions := map[string]string{
+			"namespace": rule.Namespace,
+			"kind":      rule.Kind,
+			"type":      rule.Type,
+			"reason":    rule.Reason,
+			"env":       rule.Env,
+		}
+		for field, expr := range conditions {
+			if expr == "" {
+				continue
+			}
+			re, err := regexp.Compile("^(?:" + expr + ")$")
+			if nil != err {
+				return nil, fmt.Errorf("invalid %s of index rule %d: %v", field, i, err)
+			}
+			route.matchers[field] = re
+		}
+		if route.tmpl, err = parseIndexPattern(rule.Pattern); nil != err {
+			return nil, fmt.Errorf("invalid pattern of index rule %d: %v", i, err)
+		}
+		router.routes = append(router.routes, route)
+	}
+	return router, nil
+}
+
+func parseIndexPattern(pattern string) (*template.Template, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("index pattern must not be empty")
+	}
+	return template.New("index").Funcs(indexFuncs).Parse(pattern)
+}
+
+// Index returns the index of the first rule matching ev, or the default
+// pattern if none matches.
+func (r *indexRouter) Index(ev *model.Event) (string, error) {
+	tmpl := r.tmpl
+	for _, route := range r.routes {
+		if route.match(ev) {
+			tmpl = route.tmpl
+			break
+		}
+	}
+	var buf bytes.Buffer
+	ctx := indexContext{Event: ev, Index: r.index, Time: indexTime(ev, r.documentId)}
+	if err := tmpl.Execute(&buf, ctx); nil != err {
+		return "", err
+	}
+	// index names must be lowercase
+	return strings.ToLower(buf.String()), nil
+}
+
+func (r indexRoute) match(ev *model.Event) bool {
+	values := map[string]string{
+		"namespace": ev.Namespace,
+		"kind":      ev.Kind,
+		"type":      ev.Type,
+		"reason":    ev.Reason,
+		"env":       ev.Env,
+	}
+	for field, re := range r.matchers {
+		if !re.MatchString(values[field]) {
+			return false
+		}
+	}
+	return true
+}
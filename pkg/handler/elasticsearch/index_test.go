@@ -0,0 +1,37 @@
+package elasticsearch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestIndexRouter(t *testing.T) {
+	router, err := newIndexRouter("kubernetes-events", defaultIndexPattern, DocumentIdRandom, []IndexRule{
+		{Type: "Warning", Namespace: "prod-.*", Pattern: "warnings-{{.Namespace}}-{{month .Time}}"},
+		{Namespace: "Team-A", Pattern: "{{.Index}}-{{.Namespace}}-{{week .Time}}"},
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2018, 7, 2, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		ev       *model.Event
+		excepted string
+	}{
+		{&model.Event{Time: now, Namespace: "prod-web", Type: "Warning"}, "warnings-prod-web-2018.07"},
+		{&model.Event{Time: now, Namespace: "prod-web", Type: "Normal"}, "kubernetes-events-2018.07.02"},
+		{&model.Event{Time: now, Namespace: "Team-A"}, "kubernetes-events-team-a-2018.w27"},
+	}
+	for _, c := range cases {
+		index, err := router.Index(c.ev)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if index != c.excepted {
+			t.Fatalf("excepted %s, got %s", c.excepted, index)
+		}
+	}
+}
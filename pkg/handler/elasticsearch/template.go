@@ -0,0 +1,403 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+var (
+	esTemplate            = flag.Bool("es-template", false, "install an index template for event indices at startup")
+	esTemplateName        = flag.String("es-template-name", "luxun-events", "name of the index template")
+	esTemplatePatterns    = flag.String("es-template-index-patterns", "", "comma separated index patterns the template applies to, defaults to <es-index>-*")
+	esTemplateShards      = flag.Int("es-template-shards", 1, "number of primary shards of event indices")
+	esTemplateReplicas    = flag.Int("es-template-replicas", 1, "number of replicas of event indices")
+	esLifecyclePolicy     = flag.String("es-lifecycle-policy", "", "name of the ILM (elasticsearch) or ISM (opensearch) policy attached to event indices, empty disables it")
+	esLifecycleDeleteDays = flag.Int("es-lifecycle-delete-days", 30, "days after which event indices are deleted by the lifecycle policy")
+	esRollover            = flag.Bool("es-rollover", false, "write events to the alias <es-index> rolled over by the lifecycle policy instead of time based indices")
+	esRolloverMaxAge      = flag.String("es-rollover-max-age", "1d", "age of the write index that triggers a rollover, empty disables it")
+	esRolloverMaxSize     = flag.String("es-rollover-max-size", "50gb", "size of the write index that triggers a rollover, empty disables it")
+)
+
+type TemplateConfig struct {
+	Install    bool
+	Name       string
+	Patterns   []string
+	Shards     int
+	Replicas   int
+	Policy     string
+	DeleteDays int
+	// Rollover writes events to a write alias named after the index, which
+	// is rolled over by the lifecycle policy.
+	Rollover        bool
+	RolloverMaxAge  string
+	RolloverMaxSize string
+}
+
+func templateConfigFromFlags() TemplateConfig {
+	patterns := make([]string, 0)
+	for _, pattern := range strings.Split(*esTemplatePatterns, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return TemplateConfig{
+		Install:         *esTemplate,
+		Name:            *esTemplateName,
+		Patterns:        patterns,
+		Shards:          *esTemplateShards,
+		Replicas:        *esTemplateReplicas,
+		Policy:          *esLifecyclePolicy,
+		DeleteDays:      *esLifecycleDeleteDays,
+		Rollover:        *esRollover,
+		RolloverMaxAge:  *esRolloverMaxAge,
+		RolloverMaxSize: *esRolloverMaxSize,
+	}
+}
+
+func (c TemplateConfig) Validate() error {
+	if !c.Install {
+		if c.Rollover {
+			return fmt.Errorf("es-rollover requires es-template")
+		}
+		return nil
+	}
+	switch {
+	case c.Name == "":
+		return fmt.Errorf("es-template-name must not be empty")
+	case c.Shards <= 0:
+		return fmt.Errorf("es-template-shards must be positive, got %d", c.Shards)
+	case c.Replicas < 0:
+		return fmt.Errorf("es-template-replicas must not be negative, got %d", c.Replicas)
+	case c.Policy != "" && c.DeleteDays <= 0:
+		return fmt.Errorf("es-lifecycle-delete-days must be positive, got %d", c.DeleteDays)
+	case c.Rollover && c.Policy == "":
+		return fmt.Errorf("es-rollover requires es-lifecycle-policy")
+	case c.Rollover && c.RolloverMaxAge == "" && c.RolloverMaxSize == "":
+		return fmt.Errorf("at least one of es-rollover-max-age and es-rollover-max-size must be set")
+	}
+	return nil
+}
+
+func (c TemplateConfig) indexPatterns(index string) []string {
+	if len(c.Patterns) > 0 {
+		return c.Patterns
+	}
+	return []string{index + "-*"}
+}
+
+// installTemplate puts the lifecycle policy, if any, and the index template
+// of event indices. Unless rollover is enabled, indices are already rolled
+// over by their name, so the policy only takes care of deleting them.
+func installTemplate(client *elastic.Client, version clusterVersion, index string, config TemplateConfig) error {
+	settings := map[string]interface{}{
+		"number_of_shards":   config.Shards,
+		"number_of_replicas": config.Replicas,
+	}
+	if config.Policy != "" {
+		if err := installPolicy(client, version, index, config); nil != err {
+			return err
+		}
+		if version.Distribution != distributionOpenSearch {
+			settings["index.lifecycle.name"] = config.Policy
+		}
+		if config.Rollover {
+			if version.Distribution == distributionOpenSearch {
+				settings["plugins.index_state_management.rollover_alias"] = index
+			} else {
+				settings["index.lifecycle.rollover_alias"] = index
+			}
+		}
+	}
+
+	body := map[string]interface{}{
+		"order":    0,
+		"settings": settings,
+	}
+	patterns := config.indexPatterns(index)
+	if version.Distribution != distributionOpenSearch && version.Major < 6 {
+		if len(patterns) > 1 {
+			return fmt.Errorf("%s supports only one index pattern per template", version)
+		}
+		body["template"] = patterns[0]
+	} else {
+		body["index_patterns"] = patterns
+	}
+	if version.typeless() {
+		body["mappings"] = eventMapping()
+	} else {
+		body["mappings"] = map[string]interface{}{index: eventMapping()}
+	}
+
+	_, err := client.PerformRequest(context.Background(), "PUT", "/_template/"+config.Name, nil, body)
+	if nil != err {
+		return fmt.Errorf("failed to install index template %s: %v", config.Name, err)
+	}
+	if config.Rollover {
+		return bootstrapWriteIndex(client, index)
+	}
+	return nil
+}
+
+// bootstrapWriteIndex creates the first index behind the write alias, unless
+// the alias already exists.
+func bootstrapWriteIndex(client *elastic.Client, alias string) error {
+	resp, err := client.PerformRequest(context.Background(), "GET", "/_alias/"+alias, nil, nil, http.StatusNotFound)
+	if nil != err {
+		return fmt.Errorf("failed to get write alias %s: %v", alias, err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return nil
+	}
+	body := map[string]interface{}{
+		"aliases": map[string]interface{}{
+			alias: map[string]interface{}{"is_write_index": true},
+		},
+	}
+	if _, err := client.PerformRequest(context.Background(), "PUT", "/"+alias+"-000001", nil, body); nil != err {
+		return fmt.Errorf("failed to create write index of %s: %v", alias, err)
+	}
+	return nil
+}
+
+func installPolicy(client *elastic.Client, version clusterVersion, index string, config TemplateConfig) error {
+	deleteAfter := fmt.Sprintf("%dd", config.DeleteDays)
+	var path string
+	var body map[string]interface{}
+	switch {
+	case version.Distribution == distributionOpenSearch:
+		path = "/_plugins/_ism/policies/" + config.Policy
+		actions := []interface{}{}
+		if config.Rollover {
+			rollover := make(map[string]interface{})
+			if config.RolloverMaxAge != "" {
+				rollover["min_index_age"] = config.RolloverMaxAge
+			}
+			if config.RolloverMaxSize != "" {
+				rollover["min_size"] = config.RolloverMaxSize
+			}
+			actions = append(actions, map[string]interface{}{"rollover": rollover})
+		}
+		body = map[string]interface{}{
+			"policy": map[string]interface{}{
+				"description":   "managed by luxun",
+				"default_state": "hot",
+				"states": []interface{}{
+					map[string]interface{}{
+						"name":    "hot",
+						"actions": actions,
+						"transitions": []interface{}{
+							map[string]interface{}{
+								"state_name": "delete",
+								"conditions": map[string]interface{}{"min_index_age": deleteAfter},
+							},
+						},
+					},
+					map[string]interface{}{
+						"name":        "delete",
+						"actions":     []interface{}{map[string]interface{}{"delete": map[string]interface{}{}}},
+						"transitions": []interface{}{},
+					},
+				},
+				"ism_template": []interface{}{
+					map[string]interface{}{"index_patterns": config.indexPatterns(index)},
+				},
+			},
+		}
+	case version.lifecycle():
+		path = "/_ilm/policy/" + config.Policy
+		actions := map[string]interface{}{}
+		if config.Rollover {
+			rollover := make(map[string]interface{})
+			if config.RolloverMaxAge != "" {
+				rollover["max_age"] = config.RolloverMaxAge
+			}
+			if config.RolloverMaxSize != "" {
+				rollover["max_size"] = config.RolloverMaxSize
+			}
+			actions["rollover"] = rollover
+		}
+		body = map[string]interface{}{
+			"policy": map[string]interface{}{
+				"phases": map[string]interface{}{
+					"hot": map[string]interface{}{
+						"min_age": "0ms",
+						"actions": actions,
+					},
+					"delete": map[string]interface{}{
+						"min_age": deleteAfter,
+						"actions": map[string]interface{}{"delete": map[string]interface{}{}},
+					},
+				},
+			},
+		}
+	default:
+		return fmt.Errorf("lifecycle policies are not supported by %s", version)
+	}
+
+	var params url.Values
+	if version.Distribution == distributionOpenSearch {
+		// unlike ILM, ISM refuses to overwrite an existing policy unless the
+		// update carries its sequence number and primary term.
+		var err error
+		if params, err = ismPolicyVersion(client, path); nil != err {
+			return fmt.Errorf("failed to get lifecycle policy %s: %v", config.Policy, err)
+		}
+	}
+	_, err := client.PerformRequest(context.Background(), "PUT", path, params, body)
+	if nil != err {
+		return fmt.Errorf("failed to install lifecycle policy %s: %v", config.Policy, err)
+	}
+	return nil
+}
+
+// ismPolicyVersion returns the parameters updating the ISM policy at path,
+// or nil if it does not exist yet.
+func ismPolicyVersion(client *elastic.Client, path string) (url.Values, error) {
+	resp, err := client.PerformRequest(context.Background(), "GET", path, nil, nil, http.StatusNotFound)
+	if nil != err {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	var policy struct {
+		SeqNo       int64 `json:"_seq_no"`
+		PrimaryTerm int64 `json:"_primary_term"`
+	}
+	if err := json.Unmarshal(resp.Body, &policy); nil != err {
+		return nil, err
+	}
+	return url.Values{
+		"if_seq_no":       []string{strconv.FormatInt(policy.SeqNo, 10)},
+		"if_primary_term": []string{strconv.FormatInt(policy.PrimaryTerm, 10)},
+	}, nil
+}
+
+func eventMapping() map[string]interface{} {
+	keyword := map[string]interface{}{"type": "keyword"}
+	text := map[string]interface{}{
+		"type":   "text",
+		"fields": map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 1024}},
+	}
+	date := map[string]interface{}{"type": "date"}
+	kv := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"Key":   keyword,
+			"Value": keyword,
+		},
+	}
+
+	return map[string]interface{}{
+		"dynamic_templates": []interface{}{
+			map[string]interface{}{
+				"labels": map[string]interface{}{
+					"path_match":         "labels.*",
+					"match_mapping_type": "object",
+					"mapping":            kv,
+				},
+			},
+			map[string]interface{}{
+				"annotations": map[string]interface{}{
+					"path_match":         "annotations.*",
+					"match_mapping_type": "object",
+					"mapping":            kv,
+				},
+			},
+			map[string]interface{}{
+				"container_status": map[string]interface{}{
+					"path_match":         "containerStatus.*",
+					"match_mapping_type": "object",
+					"mapping": map[string]interface{}{
+						"type": "nested",
+						"properties": map[string]interface{}{
+							"name":     keyword,
+							"state":    keyword,
+							"exitCode": map[string]interface{}{"type": "integer"},
+							"signal":   map[string]interface{}{"type": "integer"},
+							"reason":   keyword,
+							"message":  text,
+						},
+					},
+				},
+			},
+			map[string]interface{}{
+				"strings": map[string]interface{}{
+					"match_mapping_type": "string",
+					"mapping":            keyword,
+				},
+			},
+		},
+		"properties": map[string]interface{}{
+			"time":              date,
+			"name":              keyword,
+			"namespace":         keyword,
+			"creationTimestamp": date,
+			"kind":              keyword,
+			"objectName":        keyword,
+			"reason":            keyword,
+			"message":           text,
+			"firstTimestamp":    date,
+			"lastTimestamp":     date,
+			"count":             map[string]interface{}{"type": "integer"},
+			"type":              keyword,
+			"action":            keyword,
+			"eventTime":         date,
+			"env":               keyword,
+			"record":            map[string]interface{}{"type": "boolean"},
+			"podStatus":         keyword,
+			"podCondition": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"reason":  keyword,
+					"message": text,
+					"type":    keyword,
+					"status":  keyword,
+				},
+			},
+			"nodeCondition": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"type":           keyword,
+					"status":         keyword,
+					"previousStatus": keyword,
+					"reason":         keyword,
+					"message":        text,
+				},
+			},
+			"rollout": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"revision":          keyword,
+					"previousRevision":  keyword,
+					"replicas":          map[string]interface{}{"type": "integer"},
+					"updatedReplicas":   map[string]interface{}{"type": "integer"},
+					"readyReplicas":     map[string]interface{}{"type": "integer"},
+					"availableReplicas": map[string]interface{}{"type": "integer"},
+					"generation":        map[string]interface{}{"type": "long"},
+				},
+			},
+			"job": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"cronJob":          keyword,
+					"active":           map[string]interface{}{"type": "integer"},
+					"succeeded":        map[string]interface{}{"type": "integer"},
+					"failed":           map[string]interface{}{"type": "integer"},
+					"backoffLimit":     map[string]interface{}{"type": "integer"},
+					"startTime":        date,
+					"completionTime":   date,
+					"durationSeconds":  map[string]interface{}{"type": "double"},
+					"schedule":         keyword,
+					"suspended":        map[string]interface{}{"type": "boolean"},
+					"lastScheduleTime": date,
+					"missedSchedule":   date,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,216 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+type fakeCluster struct {
+	sync.Mutex
+	// existing maps paths to the bodies returned by GET, other paths are
+	// not found.
+	existing map[string]string
+	puts     []*http.Request
+	bodies   []map[string]interface{}
+}
+
+func (c *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.Lock()
+	defer c.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case "GET":
+		body, ok := c.existing[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"type":"resource_not_found_exception"},"status":404}`)
+			return
+		}
+		fmt.Fprint(w, body)
+	case "PUT":
+		var body map[string]interface{}
+		content, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(content, &body)
+		c.puts = append(c.puts, r)
+		c.bodies = append(c.bodies, body)
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newFakeClient(t *testing.T, cluster *fakeCluster) (*elastic.Client, func()) {
+	server := httptest.NewServer(cluster)
+	client, err := elastic.NewClient(elastic.SetURL(server.URL), elastic.SetSniff(false), elastic.SetHealthcheck(false))
+	if nil != err {
+		server.Close()
+		t.Fatal(err)
+	}
+	return client, server.Close
+}
+
+func TestInstallISMPolicy(t *testing.T) {
+	const path = "/_plugins/_ism/policies/events"
+	cases := []struct {
+		name        string
+		existing    map[string]string
+		seqNo       string
+		primaryTerm string
+	}{
+		{name: "missing"},
+		{
+			name:        "existing",
+			existing:    map[string]string{path: `{"_id":"events","_version":2,"_seq_no":7,"_primary_term":3,"policy":{}}`},
+			seqNo:       "7",
+			primaryTerm: "3",
+		},
+	}
+	version := clusterVersion{Distribution: distributionOpenSearch, Major: 2}
+	config := TemplateConfig{Install: true, Name: "events", Shards: 1, Policy: "events", DeleteDays: 30}
+	for _, c := range cases {
+		cluster := &fakeCluster{existing: c.existing}
+		client, stop := newFakeClient(t, cluster)
+		if err := installPolicy(client, version, "events", config); nil != err {
+			stop()
+			t.Fatalf("%s: excepted policy to be installed, got %v", c.name, err)
+		}
+		stop()
+		if len(cluster.puts) != 1 || cluster.puts[0].URL.Path != path {
+			t.Fatalf("%s: excepted one PUT of %s, got %d", c.name, path, len(cluster.puts))
+		}
+		query := cluster.puts[0].URL.Query()
+		if query.Get("if_seq_no") != c.seqNo || query.Get("if_primary_term") != c.primaryTerm {
+			t.Fatalf("%s: excepted if_seq_no=%q and if_primary_term=%q, got %v", c.name, c.seqNo, c.primaryTerm, query)
+		}
+	}
+}
+
+func TestInstallRolloverTemplate(t *testing.T) {
+	cases := []struct {
+		name     string
+		version  clusterVersion
+		existing map[string]string
+		puts     []string
+		setting  string
+	}{
+		{
+			name:    "elasticsearch",
+			version: clusterVersion{Major: 7, Minor: 10},
+			puts:    []string{"/_ilm/policy/events", "/_template/events", "/kubernetes-events-000001"},
+			setting: "index.lifecycle.rollover_alias",
+		},
+		{
+			name:    "opensearch",
+			version: clusterVersion{Distribution: distributionOpenSearch, Major: 2},
+			puts:    []string{"/_plugins/_ism/policies/events", "/_template/events", "/kubernetes-events-000001"},
+			setting: "plugins.index_state_management.rollover_alias",
+		},
+		{
+			name:     "existing alias",
+			version:  clusterVersion{Major: 7, Minor: 10},
+			existing: map[string]string{"/_alias/kubernetes-events": `{"kubernetes-events-000003":{"aliases":{"kubernetes-events":{}}}}`},
+			puts:     []string{"/_ilm/policy/events", "/_template/events"},
+			setting:  "index.lifecycle.rollover_alias",
+		},
+	}
+	config := TemplateConfig{
+		Install:         true,
+		Name:            "events",
+		Shards:          1,
+		Policy:          "events",
+		DeleteDays:      30,
+		Rollover:        true,
+		RolloverMaxAge:  "1d",
+		RolloverMaxSize: "50gb",
+	}
+	for _, c := range cases {
+		cluster := &fakeCluster{existing: c.existing}
+		client, stop := newFakeClient(t, cluster)
+		if err := installTemplate(client, c.version, "kubernetes-events", config); nil != err {
+			stop()
+			t.Fatalf("%s: excepted template to be installed, got %v", c.name, err)
+		}
+		stop()
+		if len(cluster.puts) != len(c.puts) {
+			t.Fatalf("%s: excepted %d PUTs, got %d", c.name, len(c.puts), len(cluster.puts))
+		}
+		for i, path := range c.puts {
+			if cluster.puts[i].URL.Path != path {
+				t.Fatalf("%s: excepted PUT %d of %s, got %s", c.name, i, path, cluster.puts[i].URL.Path)
+			}
+		}
+		settings := cluster.bodies[1]["settings"].(map[string]interface{})
+		if settings[c.setting] != "kubernetes-events" {
+			t.Fatalf("%s: excepted %s to be the write alias, got %v", c.name, c.setting, settings)
+		}
+		if !hasRollover(cluster.bodies[0]) {
+			t.Fatalf("%s: excepted a rollover action in the policy, got %v", c.name, cluster.bodies[0])
+		}
+	}
+}
+
+func hasRollover(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if key == "rollover" || hasRollover(value) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if hasRollover(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestRolloverConfig(t *testing.T) {
+	config := Config{
+		Index:        "kubernetes-events",
+		IndexPattern: defaultIndexPattern,
+		Urls:         []string{"http://localhost:9200"},
+		BulkActions:  defaultBulkActions,
+		BulkSize:     defaultBulkSize,
+		BulkWorkers:  defaultBulkWorkers,
+		QueueSize:    defaultQueueSize,
+		DocumentId:   DocumentIdRandom,
+		Template: TemplateConfig{
+			Install:        true,
+			Name:           "events",
+			Shards:         1,
+			Policy:         "events",
+			DeleteDays:     30,
+			Rollover:       true,
+			RolloverMaxAge: "1d",
+		},
+	}
+	if err := config.Validate(); nil != err {
+		t.Fatalf("excepted rollover config to be valid, got %v", err)
+	}
+
+	invalid := config
+	invalid.IndexPattern = "{{.Index}}-{{.Namespace}}"
+	if err := invalid.Validate(); nil == err {
+		t.Fatalf("excepted rollover with an index pattern to fail")
+	}
+	invalid = config
+	invalid.Template.Policy = ""
+	if err := invalid.Validate(); nil == err {
+		t.Fatalf("excepted rollover without a lifecycle policy to fail")
+	}
+	invalid = config
+	invalid.Template.Install = false
+	if err := invalid.Validate(); nil == err {
+		t.Fatalf("excepted rollover without a template to fail")
+	}
+}
@@ -0,0 +1,88 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+const distributionOpenSearch = "opensearch"
+
+var esVersion = flag.String("es-version", "auto", "version of elasticsearch, e.g. 5, 6.8, 7, 8 or opensearch, auto detects it at startup")
+
+type clusterVersion struct {
+	Distribution string
+	Major        int
+	Minor        int
+}
+
+// typeless reports whether the cluster has removed mapping types, in which
+// case bulk requests must not carry a _type.
+func (v clusterVersion) typeless() bool {
+	return v.Distribution == distributionOpenSearch || v.Major >= 7
+}
+
+// lifecycle reports whether the cluster has index lifecycle management,
+// which is added in elasticsearch 6.6.
+func (v clusterVersion) lifecycle() bool {
+	return v.Distribution != distributionOpenSearch && (v.Major > 6 || (v.Major == 6 && v.Minor >= 6))
+}
+
+func (v clusterVersion) String() string {
+	if v.Distribution == distributionOpenSearch {
+		return fmt.Sprintf("opensearch %d.%d", v.Major, v.Minor)
+	}
+	return fmt.Sprintf("elasticsearch %d.%d", v.Major, v.Minor)
+}
+
+func parseVersion(version string) (clusterVersion, error) {
+	if strings.EqualFold(version, distributionOpenSearch) {
+		return clusterVersion{Distribution: distributionOpenSearch}, nil
+	}
+	parts := strings.SplitN(version, ".", 3)
+	major, err := strconv.Atoi(parts[0])
+	if nil != err {
+		return clusterVersion{}, fmt.Errorf("invalid elasticsearch version %q", version)
+	}
+	v := clusterVersion{Major: major}
+	if len(parts) > 1 {
+		if v.Minor, err = strconv.Atoi(parts[1]); nil != err {
+			return clusterVersion{}, fmt.Errorf("invalid elasticsearch version %q", version)
+		}
+	}
+	return v, nil
+}
+
+func detectVersion(client *elastic.Client) (clusterVersion, error) {
+	res, err := client.PerformRequest(context.Background(), "GET", "/", nil, nil)
+	if nil != err {
+		return clusterVersion{}, fmt.Errorf("failed to detect elasticsearch version: %v", err)
+	}
+	var info struct {
+		Version struct {
+			Number       string `json:"number"`
+			Distribution string `json:"distribution"`
+		} `json:"version"`
+	}
+	if err := json.Unmarshal(res.Body, &info); nil != err {
+		return clusterVersion{}, fmt.Errorf("failed to detect elasticsearch version: %v", err)
+	}
+	v, err := parseVersion(info.Version.Number)
+	if nil != err {
+		return v, err
+	}
+	v.Distribution = info.Version.Distribution
+	return v, nil
+}
+
+func resolveVersion(client *elastic.Client, version string) (clusterVersion, error) {
+	if version == "" || version == "auto" {
+		return detectVersion(client)
+	}
+	return parseVersion(version)
+}
@@ -0,0 +1,31 @@
+package elasticsearch
+
+import (
+	"testing"
+)
+
+func TestVersionLifecycle(t *testing.T) {
+	cases := []struct {
+		version  string
+		excepted bool
+	}{
+		{"5.6.16", false},
+		{"6", false},
+		{"6.5.4", false},
+		{"6.6.0", true},
+		{"7.10.2", true},
+		{"opensearch", false},
+	}
+	for _, c := range cases {
+		v, err := parseVersion(c.version)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if v.lifecycle() != c.excepted {
+			t.Fatalf("excepted lifecycle of %s to be %v", c.version, c.excepted)
+		}
+	}
+	if _, err := parseVersion("6.x"); nil == err {
+		t.Fatalf("excepted invalid minor version to fail")
+	}
+}
@@ -0,0 +1,254 @@
+package file
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/handler"
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+const dayLayout = "2006.01.02"
+
+var (
+	fileDir        = flag.String("file-dir", "/var/log/luxun", "directory that event files are written to")
+	fileName       = flag.String("file-name", "kubernetes-events", "base name of event files")
+	fileMaxSize    = flag.Int64("file-max-size", 100, "max size in megabytes of an event file before it is rotated, 0 disables size rotation")
+	fileCompress   = flag.Bool("file-compress", true, "gzip rotated event files")
+	fileMaxBackups = flag.Int("file-max-backups", 30, "max number of rotated event files to keep, 0 keeps all")
+	fileMaxAge     = flag.Duration("file-max-age", 0, "max age of rotated event files to keep, 0 keeps all")
+)
+
+type Config struct {
+	Dir        string
+	Name       string
+	MaxSize    int64
+	Compress   bool
+	MaxBackups int
+	MaxAge     time.Duration
+}
+
+// FileWriter appends events as JSON lines to <name>-<day>.log. The file is
+// rotated when the day of the event changes or it grows over MaxSize, files
+// of other days left by a previous run are rotated on open.
+type FileWriter struct {
+	config Config
+	lock   sync.Mutex
+	file   *os.File
+	day    string
+	size   int64
+}
+
+func init() {
+	handler.RegisterSink("file", NewSink)
+}
+
+func NewSink() (handler.Sink, error) {
+	return NewFileWriter(Config{
+		Dir:        *fileDir,
+		Name:       *fileName,
+		MaxSize:    *fileMaxSize * 1024 * 1024,
+		Compress:   *fileCompress,
+		MaxBackups: *fileMaxBackups,
+		MaxAge:     *fileMaxAge,
+	})
+}
+
+func NewFileWriter(config Config) (*FileWriter, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("file name is required")
+	}
+	if err := os.MkdirAll(config.Dir, 0755); nil != err {
+		return nil, err
+	}
+	return &FileWriter{config: config}, nil
+}
+
+func (fw *FileWriter) Name() string {
+	return "file"
+}
+
+func (fw *FileWriter) Write(ev *model.Event) error {
+	line, err := json.Marshal(ev)
+	if nil != err {
+		return err
+	}
+	line = append(line, '\n')
+
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+
+	day := ev.Time.Format(dayLayout)
+	if nil != fw.file && (day != fw.day || (fw.config.MaxSize > 0 && fw.size > 0 && fw.size+int64(len(line)) > fw.config.MaxSize)) {
+		if err := fw.rotate(); nil != err {
+			return err
+		}
+	}
+	if nil == fw.file {
+		if err := fw.open(day); nil != err {
+			return err
+		}
+	}
+	n, err := fw.file.Write(line)
+	fw.size += int64(n)
+	return err
+}
+
+func (fw *FileWriter) Stop() {
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+	if nil != fw.file {
+		fw.file.Close()
+		fw.file = nil
+	}
+}
+
+func (fw *FileWriter) activePath(day string) string {
+	return filepath.Join(fw.config.Dir, fmt.Sprintf("%s-%s.log", fw.config.Name, day))
+}
+
+func (fw *FileWriter) open(day string) error {
+	fw.rotateStale(day)
+	f, err := os.OpenFile(fw.activePath(day), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if nil != err {
+		return err
+	}
+	info, err := f.Stat()
+	if nil != err {
+		f.Close()
+		return err
+	}
+	fw.file = f
+	fw.day = day
+	fw.size = info.Size()
+	return nil
+}
+
+// rotate closes the active file and rotates it.
+func (fw *FileWriter) rotate() error {
+	if err := fw.file.Close(); nil != err {
+		return err
+	}
+	fw.file = nil
+	return fw.rotateDay(fw.day)
+}
+
+// rotateStale rotates the active files of days other than day, which are
+// left if we were stopped before the day changed.
+func (fw *FileWriter) rotateStale(day string) {
+	matches, err := filepath.Glob(filepath.Join(fw.config.Dir, fw.config.Name+"-*.log"))
+	if nil != err {
+		return
+	}
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(fw.config.Name) + `-(\d{4}\.\d{2}\.\d{2})\.log$`)
+	for _, path := range matches {
+		m := pattern.FindStringSubmatch(filepath.Base(path))
+		if nil == m || m[1] == day {
+			continue
+		}
+		if err := fw.rotateDay(m[1]); nil != err {
+			fmt.Printf("failed to rotate %s: %s\n", path, err.Error())
+		}
+	}
+}
+
+// rotateDay renames the active file of day to <name>-<day>.<seq>.log and
+// compresses it and prunes old backups if configured.
+func (fw *FileWriter) rotateDay(day string) error {
+	active := fw.activePath(day)
+	var backup string
+	for seq := 1; ; seq++ {
+		backup = filepath.Join(fw.config.Dir, fmt.Sprintf("%s-%s.%d.log", fw.config.Name, day, seq))
+		if !exists(backup) && !exists(backup+".gz") {
+			break
+		}
+	}
+	if err := os.Rename(active, backup); nil != err {
+		return err
+	}
+	if fw.config.Compress {
+		if err := compress(backup); nil != err {
+			fmt.Printf("failed to compress %s: %s\n", backup, err.Error())
+		}
+	}
+	fw.prune()
+	return nil
+}
+
+func (fw *FileWriter) prune() {
+	matches, err := filepath.Glob(filepath.Join(fw.config.Dir, fw.config.Name+"-*"))
+	if nil != err {
+		return
+	}
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(fw.config.Name) + `-\d{4}\.\d{2}\.\d{2}\.\d+\.log(\.gz)?$`)
+	type backup struct {
+		path    string
+		modTime time.Time
+	}
+	backups := make([]backup, 0, len(matches))
+	for _, path := range matches {
+		if !pattern.MatchString(filepath.Base(path)) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if nil != err {
+			continue
+		}
+		backups = append(backups, backup{path, info.ModTime()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].modTime.After(backups[j].modTime)
+	})
+	for i, b := range backups {
+		expired := fw.config.MaxAge > 0 && time.Since(b.modTime) > fw.config.MaxAge
+		if (fw.config.MaxBackups > 0 && i >= fw.config.MaxBackups) || expired {
+			if err := os.Remove(b.path); nil != err {
+				fmt.Printf("failed to remove %s: %s\n", b.path, err.Error())
+			}
+		}
+	}
+}
+
+func compress(path string) error {
+	src, err := os.Open(path)
+	if nil != err {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if nil != err {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); nil != err {
+		gz.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); nil != err {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); nil != err {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,85 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestFileRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "luxun-file")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fw, err := NewFileWriter(Config{
+		Dir:        dir,
+		Name:       "events",
+		MaxSize:    200,
+		Compress:   true,
+		MaxBackups: 2,
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	day1 := time.Date(2018, 7, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	for i := 0; i < 10; i++ {
+		if err := fw.Write(&model.Event{Time: day1, Name: "pod", Reason: "BackOff"}); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.Write(&model.Event{Time: day2, Name: "pod"}); nil != err {
+		t.Fatal(err)
+	}
+	fw.Stop()
+
+	if _, err := os.Stat(filepath.Join(dir, "events-2018.07.02.log")); nil != err {
+		t.Fatalf("excepted active file of the new day: %v", err)
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "events-2018.07.01.*.log.gz"))
+	if len(backups) != 2 {
+		t.Fatalf("excepted 2 compressed backups to be kept, got %v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "events-2018.07.01.log")); !os.IsNotExist(err) {
+		t.Fatalf("excepted active file of the previous day to be rotated")
+	}
+}
+
+func TestFileRotationOnOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "luxun-file")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the previous run was stopped before the day changed
+	stale := filepath.Join(dir, "events-2018.07.01.log")
+	if err := ioutil.WriteFile(stale, []byte("{}\n"), 0644); nil != err {
+		t.Fatal(err)
+	}
+	fw, err := NewFileWriter(Config{Dir: dir, Name: "events"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err := fw.Write(&model.Event{Time: time.Date(2018, 7, 2, 10, 0, 0, 0, time.UTC)}); nil != err {
+		t.Fatal(err)
+	}
+	fw.Stop()
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("excepted active file of the previous day to be rotated on open")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "events-2018.07.01.1.log")); nil != err {
+		t.Fatalf("excepted backup of the previous day: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "events-2018.07.02.log")); nil != err {
+		t.Fatalf("excepted active file of the new day: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+// Sink is a backend that events are finally delivered to.
+type Sink interface {
+	Name() string
+	Write(ev *model.Event) error
+	Stop()
+}
+
+// AckSink is a Sink that delivers events in the background, Write only
+// means the event is accepted. WriteAck calls done once the event is
+// delivered or failed to be, done is not called if WriteAck returns an
+// error.
+type AckSink interface {
+	Sink
+	WriteAck(ev *model.Event, done func(err error)) error
+}
+
+// Deliver writes ev to s and calls done once it is delivered or failed to
+// be.
+func Deliver(s Sink, ev *model.Event, done func(err error)) {
+	if as, ok := s.(AckSink); ok {
+		if err := as.WriteAck(ev, done); nil != err {
+			done(err)
+		}
+		return
+	}
+	done(s.Write(ev))
+}
+
+// PermanentError is a failure that retrying does not fix, e.g. an event
+// rejected by the backend.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func IsPermanent(err error) bool {
+	_, ok := err.(*PermanentError)
+	return ok
+}
+
+type SinkBuilder func() (Sink, error)
+
+var sinkBuilders = make(map[string]SinkBuilder)
+
+func RegisterSink(name string, fn SinkBuilder) {
+	sinkBuilders[name] = fn
+}
+
+func NewSink(name string) (Sink, error) {
+	builder, ok := sinkBuilders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q, available sinks: %v", name, Sinks())
+	}
+	return builder()
+}
+
+func Sinks() []string {
+	names := make([]string, 0, len(sinkBuilders))
+	for name := range sinkBuilders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
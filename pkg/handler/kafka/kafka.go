@@ -0,0 +1,186 @@
+package kafka
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/jojohappy/luxun/pkg/handler"
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+var (
+	kafkaBrokers        = flag.String("kafka-brokers", "", "comma separated list of kafka brokers")
+	kafkaTopic          = flag.String("kafka-topic", "kubernetes-events", "kafka topic that events are published to")
+	kafkaClientId       = flag.String("kafka-client-id", "luxun", "client id reported to the kafka brokers")
+	kafkaRequiredAcks   = flag.String("kafka-required-acks", "local", "acks required from the brokers, one of none, local or all")
+	kafkaCompression    = flag.String("kafka-compression", "none", "compression codec, one of none, gzip, snappy or lz4")
+	kafkaFlushMessages  = flag.Int("kafka-flush-messages", 0, "number of messages that triggers a flush, 0 means no limit")
+	kafkaFlushBytes     = flag.Int("kafka-flush-bytes", 0, "number of bytes that triggers a flush, 0 means the sarama default")
+	kafkaFlushFrequency = flag.Duration("kafka-flush-frequency", 500*time.Millisecond, "max time a message is buffered before being flushed")
+	kafkaMaxRetries     = flag.Int("kafka-max-retries", 3, "max number of retries of a failed produce request")
+)
+
+type KafkaClient struct {
+	producer   sarama.AsyncProducer
+	topic      string
+	shutdownCh chan struct{}
+	wg         sync.WaitGroup
+}
+
+func init() {
+	handler.RegisterSink("kafka", NewSink)
+}
+
+func NewSink() (handler.Sink, error) {
+	if *kafkaBrokers == "" {
+		return nil, fmt.Errorf("kafka brokers are required")
+	}
+	config, err := newProducerConfig()
+	if nil != err {
+		return nil, err
+	}
+	producer, err := sarama.NewAsyncProducer(strings.Split(*kafkaBrokers, ","), config)
+	if nil != err {
+		return nil, err
+	}
+	kc := NewKafkaClient(producer, *kafkaTopic)
+	kc.Run()
+	return kc, nil
+}
+
+func newProducerConfig() (*sarama.Config, error) {
+	config := sarama.NewConfig()
+	config.ClientID = *kafkaClientId
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+	config.Producer.Return.Errors = true
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Max = *kafkaMaxRetries
+	config.Producer.Flush.Messages = *kafkaFlushMessages
+	config.Producer.Flush.Bytes = *kafkaFlushBytes
+	config.Producer.Flush.Frequency = *kafkaFlushFrequency
+
+	switch *kafkaRequiredAcks {
+	case "none":
+		config.Producer.RequiredAcks = sarama.NoResponse
+	case "local":
+		config.Producer.RequiredAcks = sarama.WaitForLocal
+	case "all":
+		config.Producer.RequiredAcks = sarama.WaitForAll
+	default:
+		return nil, fmt.Errorf("unknown kafka required acks %q", *kafkaRequiredAcks)
+	}
+
+	switch *kafkaCompression {
+	case "none":
+		config.Producer.Compression = sarama.CompressionNone
+	case "gzip":
+		config.Producer.Compression = sarama.CompressionGZIP
+	case "snappy":
+		config.Producer.Compression = sarama.CompressionSnappy
+	case "lz4":
+		config.Producer.Compression = sarama.CompressionLZ4
+	default:
+		return nil, fmt.Errorf("unknown kafka compression %q", *kafkaCompression)
+	}
+
+	if err := config.Validate(); nil != err {
+		return nil, fmt.Errorf("invalid kafka config: %v", err)
+	}
+	return config, nil
+}
+
+func NewKafkaClient(producer sarama.AsyncProducer, topic string) *KafkaClient {
+	return &KafkaClient{
+		producer:   producer,
+		topic:      topic,
+		shutdownCh: make(chan struct{}),
+	}
+}
+
+func (kc *KafkaClient) Name() string {
+	return "kafka"
+}
+
+// Run reports the result of every message published by WriteAck.
+func (kc *KafkaClient) Run() {
+	kc.wg.Add(2)
+	go func() {
+		defer kc.wg.Done()
+		for msg := range kc.producer.Successes() {
+			if done, ok := msg.Metadata.(func(err error)); ok {
+				done(nil)
+			}
+		}
+	}()
+	go func() {
+		defer kc.wg.Done()
+		for err := range kc.producer.Errors() {
+			fmt.Printf("failed to publish event to kafka: %s\n", err.Error())
+			if done, ok := err.Msg.Metadata.(func(err error)); ok {
+				if permanent(err.Err) {
+					done(handler.Permanent(err))
+				} else {
+					done(err)
+				}
+			}
+		}
+	}()
+}
+
+// permanent reports whether retrying does not help a failed message.
+func permanent(err error) bool {
+	switch err {
+	case sarama.ErrMessageSizeTooLarge, sarama.ErrInvalidMessage:
+		return true
+	}
+	return false
+}
+
+// Write publishes the event keyed by the object it is about, so that all
+// events of one object land in the same partition and keep their order.
+func (kc *KafkaClient) Write(ev *model.Event) error {
+	return kc.WriteAck(ev, nil)
+}
+
+// WriteAck publishes ev, done is called once the brokers acknowledge it or
+// the producer gives up.
+func (kc *KafkaClient) WriteAck(ev *model.Event, done func(err error)) error {
+	msg, err := kc.message(ev)
+	if nil != err {
+		return handler.Permanent(err)
+	}
+	if nil != done {
+		msg.Metadata = done
+	}
+	select {
+	case kc.producer.Input() <- msg:
+		return nil
+	case <-kc.shutdownCh:
+		return fmt.Errorf("kafka sink is stopped")
+	}
+}
+
+func (kc *KafkaClient) message(ev *model.Event) (*sarama.ProducerMessage, error) {
+	value, err := json.Marshal(ev)
+	if nil != err {
+		return nil, err
+	}
+	return &sarama.ProducerMessage{
+		Topic: kc.topic,
+		Key:   sarama.StringEncoder(ev.ObjectKey()),
+		Value: sarama.ByteEncoder(value),
+	}, nil
+}
+
+// Stop flushes buffered messages and waits for the producer to exit.
+func (kc *KafkaClient) Stop() {
+	close(kc.shutdownCh)
+	kc.producer.AsyncClose()
+	kc.wg.Wait()
+}
@@ -0,0 +1,75 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+
+	"github.com/jojohappy/luxun/pkg/handler"
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestKafkaMessage(t *testing.T) {
+	kc := NewKafkaClient(nil, "events")
+	ev := &model.Event{Namespace: "default", Kind: "Pod", ObjectName: "web-0", Reason: "BackOff"}
+	msg, err := kc.message(ev)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if msg.Topic != "events" {
+		t.Fatalf("excepted topic events, got %s", msg.Topic)
+	}
+	key, _ := msg.Key.Encode()
+	if string(key) != "default/Pod/web-0" {
+		t.Fatalf("excepted key default/Pod/web-0, got %s", key)
+	}
+	value, _ := msg.Value.Encode()
+	var decoded model.Event
+	if err := json.Unmarshal(value, &decoded); nil != err {
+		t.Fatal(err)
+	}
+	if decoded.Reason != ev.Reason {
+		t.Fatalf("excepted reason %s, got %s", ev.Reason, decoded.Reason)
+	}
+}
+
+func TestKafkaAck(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	producer.ExpectInputAndSucceed()
+	producer.ExpectInputAndFail(sarama.ErrMessageSizeTooLarge)
+	producer.ExpectInputAndFail(sarama.ErrNotLeaderForPartition)
+
+	kc := NewKafkaClient(producer, "events")
+	kc.Run()
+
+	results := make(chan error, 3)
+	for _, reason := range []string{"Pulled", "BackOff", "Failed"} {
+		ev := &model.Event{Namespace: "default", Kind: "Pod", ObjectName: "web-0", Reason: reason}
+		if err := kc.WriteAck(ev, func(err error) { results <- err }); nil != err {
+			t.Fatal(err)
+		}
+	}
+	kc.Stop()
+	close(results)
+
+	// successes and errors are reported by different goroutines
+	acked := make(map[string]int)
+	for err := range results {
+		switch {
+		case nil == err:
+			acked["ok"]++
+		case handler.IsPermanent(err):
+			acked["permanent"]++
+		default:
+			acked["retry"]++
+		}
+	}
+	if fmt.Sprint(acked) != "map[ok:1 permanent:1 retry:1]" {
+		t.Fatalf("excepted every message acked once, got %v", acked)
+	}
+}
@@ -0,0 +1,327 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/handler"
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = 5 * time.Second
+	defaultMaxRetries    = 3
+	defaultRetryBackoff  = time.Second
+	defaultTimeout       = 10 * time.Second
+	maxRetryBackoff      = time.Minute
+)
+
+var (
+	webhookUrl           = flag.String("webhook-url", "", "URL that event batches are posted to")
+	webhookHeaders       = flag.String("webhook-headers", "", "comma separated list of extra headers in the form of key:value")
+	webhookBearerToken   = flag.String("webhook-bearer-token", "", "bearer token sent in the Authorization header")
+	webhookUsername      = flag.String("webhook-username", "", "username of basic authentication")
+	webhookPassword      = flag.String("webhook-password", "", "password of basic authentication")
+	webhookTemplate      = flag.String("webhook-template", "", "path to a Go template that renders the request body, defaults to a JSON array of events")
+	webhookContentType   = flag.String("webhook-content-type", "application/json", "Content-Type of the request body")
+	webhookBatchSize     = flag.Int("webhook-batch-size", defaultBatchSize, "max number of events sent in one request")
+	webhookFlushInterval = flag.Duration("webhook-flush-interval", defaultFlushInterval, "max time an event waits in the batch before being sent")
+	webhookMaxRetries    = flag.Int("webhook-max-retries", defaultMaxRetries, "max number of retries of a failed request")
+	webhookRetryBackoff  = flag.Duration("webhook-retry-backoff", defaultRetryBackoff, "initial backoff between retries, doubled on every attempt")
+	webhookTimeout       = flag.Duration("webhook-timeout", defaultTimeout, "timeout of a single request")
+)
+
+type Config struct {
+	Url           string
+	Headers       map[string]string
+	BearerToken   string
+	Username      string
+	Password      string
+	Template      *template.Template
+	ContentType   string
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	RetryBackoff  time.Duration
+	Timeout       time.Duration
+}
+
+type WebhookClient struct {
+	config     Config
+	client     *http.Client
+	q          chan entry
+	shutdownCh chan struct{}
+	stopped    sync.Once
+	wg         sync.WaitGroup
+}
+
+type entry struct {
+	ev   *model.Event
+	done func(err error)
+}
+
+func init() {
+	handler.RegisterSink("webhook", NewSink)
+}
+
+func NewSink() (handler.Sink, error) {
+	config, err := configFromFlags()
+	if nil != err {
+		return nil, err
+	}
+	wc, err := NewWebhookClient(config)
+	if nil != err {
+		return nil, err
+	}
+	wc.Run()
+	return wc, nil
+}
+
+func configFromFlags() (Config, error) {
+	config := Config{
+		Url:           *webhookUrl,
+		Headers:       make(map[string]string),
+		BearerToken:   *webhookBearerToken,
+		Username:      *webhookUsername,
+		Password:      *webhookPassword,
+		ContentType:   *webhookContentType,
+		BatchSize:     *webhookBatchSize,
+		FlushInterval: *webhookFlushInterval,
+		MaxRetries:    *webhookMaxRetries,
+		RetryBackoff:  *webhookRetryBackoff,
+		Timeout:       *webhookTimeout,
+	}
+	for _, header := range strings.Split(*webhookHeaders, ",") {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+		kv := strings.SplitN(header, ":", 2)
+		if len(kv) != 2 {
+			return config, fmt.Errorf("invalid webhook header %q, expected key:value", header)
+		}
+		config.Headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	if *webhookTemplate != "" {
+		content, err := ioutil.ReadFile(*webhookTemplate)
+		if nil != err {
+			return config, fmt.Errorf("failed to read webhook template: %v", err)
+		}
+		config.Template, err = ParseTemplate(string(content))
+		if nil != err {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+// ParseTemplate parses a body template. The template is executed with the
+// batch of events ([]*model.Event) and may use the "json" function to
+// encode any value.
+func ParseTemplate(text string) (*template.Template, error) {
+	t, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+	if nil != err {
+		return nil, fmt.Errorf("failed to parse webhook template: %v", err)
+	}
+	return t, nil
+}
+
+func NewWebhookClient(config Config) (*WebhookClient, error) {
+	if config.Url == "" {
+		return nil, fmt.Errorf("webhook url is required")
+	}
+	if config.BearerToken != "" && config.Username != "" {
+		return nil, fmt.Errorf("bearer token and basic authentication are mutually exclusive")
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultRetryBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.ContentType == "" {
+		config.ContentType = "application/json"
+	}
+	return &WebhookClient{
+		config:     config,
+		client:     &http.Client{Timeout: config.Timeout},
+		q:          make(chan entry, config.BatchSize),
+		shutdownCh: make(chan struct{}),
+	}, nil
+}
+
+func (wc *WebhookClient) Name() string {
+	return "webhook"
+}
+
+func (wc *WebhookClient) Run() {
+	wc.wg.Add(1)
+	go func() {
+		defer wc.wg.Done()
+		wc.runBatchRoutine()
+	}()
+}
+
+func (wc *WebhookClient) Write(ev *model.Event) error {
+	return wc.WriteAck(ev, nil)
+}
+
+// WriteAck adds ev to the batch, done is called once the batch is sent or
+// failed to be after all retries.
+func (wc *WebhookClient) WriteAck(ev *model.Event, done func(err error)) error {
+	select {
+	case wc.q <- entry{ev: ev, done: done}:
+		return nil
+	case <-wc.shutdownCh:
+		return fmt.Errorf("webhook sink is stopped")
+	}
+}
+
+// Stop sends the pending batch and waits for the sender to exit.
+func (wc *WebhookClient) Stop() {
+	wc.stopped.Do(func() {
+		close(wc.shutdownCh)
+	})
+	wc.wg.Wait()
+}
+
+func (wc *WebhookClient) runBatchRoutine() {
+	ticker := time.NewTicker(wc.config.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]entry, 0, wc.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		events := make([]*model.Event, len(batch))
+		for i, e := range batch {
+			events[i] = e.ev
+		}
+		err := wc.send(events)
+		if nil != err {
+			fmt.Printf("failed to send %d events to webhook: %s\n", len(batch), err.Error())
+		}
+		for _, e := range batch {
+			if nil != e.done {
+				e.done(err)
+			}
+		}
+		batch = make([]entry, 0, wc.config.BatchSize)
+	}
+	for {
+		select {
+		case e := <-wc.q:
+			batch = append(batch, e)
+			if len(batch) >= wc.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-wc.shutdownCh:
+			for {
+				select {
+				case e := <-wc.q:
+					batch = append(batch, e)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts the batch, failures that retrying does not fix are returned
+// as permanent errors.
+func (wc *WebhookClient) send(batch []*model.Event) error {
+	body, err := wc.render(batch)
+	if nil != err {
+		return handler.Permanent(err)
+	}
+	backoff := wc.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := wc.post(body)
+		if nil == err {
+			return nil
+		}
+		if !retry {
+			return handler.Permanent(err)
+		}
+		if attempt >= wc.config.MaxRetries {
+			return err
+		}
+		fmt.Printf("failed to post to webhook (will retry in %v): %s\n", backoff, err.Error())
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+func (wc *WebhookClient) render(batch []*model.Event) ([]byte, error) {
+	if nil == wc.config.Template {
+		return json.Marshal(batch)
+	}
+	var buf bytes.Buffer
+	if err := wc.config.Template.Execute(&buf, batch); nil != err {
+		return nil, fmt.Errorf("failed to render webhook template: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// post sends the body once and reports whether a failure is worth retrying.
+func (wc *WebhookClient) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, wc.config.Url, bytes.NewReader(body))
+	if nil != err {
+		return false, err
+	}
+	req.Header.Set("Content-Type", wc.config.ContentType)
+	for k, v := range wc.config.Headers {
+		req.Header.Set(k, v)
+	}
+	if wc.config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+wc.config.BearerToken)
+	} else if wc.config.Username != "" {
+		req.SetBasicAuth(wc.config.Username, wc.config.Password)
+	}
+
+	resp, err := wc.client.Do(req)
+	if nil != err {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook responded with %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+}
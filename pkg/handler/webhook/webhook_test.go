@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/handler"
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+type recorder struct {
+	lock     sync.Mutex
+	bodies   [][]byte
+	headers  []http.Header
+	failures int
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	r.bodies = append(r.bodies, body)
+	r.headers = append(r.headers, req.Header)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (r *recorder) requests() ([][]byte, []http.Header) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.bodies, r.headers
+}
+
+func TestWebhookBatch(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	wc, err := NewWebhookClient(Config{
+		Url:           server.URL,
+		Headers:       map[string]string{"X-Cluster": "test"},
+		BearerToken:   "secret",
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	wc.Run()
+	wc.Write(&model.Event{Name: "a"})
+	wc.Write(&model.Event{Name: "b"})
+	wc.Write(&model.Event{Name: "c"})
+	wc.Stop()
+
+	bodies, headers := rec.requests()
+	if len(bodies) != 2 {
+		t.Fatalf("excepted 2 requests, got %d", len(bodies))
+	}
+	var events []*model.Event
+	if err := json.Unmarshal(bodies[0], &events); nil != err {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Name != "a" || events[1].Name != "b" {
+		t.Fatalf("unexpected first batch %s", bodies[0])
+	}
+	if got := headers[0].Get("Authorization"); got != "Bearer secret" {
+		t.Fatalf("excepted bearer token, got %q", got)
+	}
+	if got := headers[0].Get("X-Cluster"); got != "test" {
+		t.Fatalf("excepted custom header, got %q", got)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	rec := &recorder{failures: 2}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	wc, err := NewWebhookClient(Config{
+		Url:          server.URL,
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err := wc.send([]*model.Event{{Name: "a"}}); nil != err {
+		t.Fatalf("excepted retries to succeed, got %v", err)
+	}
+	if bodies, _ := rec.requests(); len(bodies) != 1 {
+		t.Fatalf("excepted 1 delivered request, got %d", len(bodies))
+	}
+}
+
+func TestWebhookAck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	wc, err := NewWebhookClient(Config{
+		Url:           server.URL,
+		MaxRetries:    3,
+		RetryBackoff:  time.Millisecond,
+		FlushInterval: time.Hour,
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	wc.Run()
+	var acked error
+	if err := wc.WriteAck(&model.Event{Name: "a"}, func(err error) { acked = err }); nil != err {
+		t.Fatal(err)
+	}
+	wc.Stop()
+	if !handler.IsPermanent(acked) {
+		t.Fatalf("excepted a rejected batch to be acked with a permanent error, got %v", acked)
+	}
+	// stopping again is a no-op
+	wc.Stop()
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	tmpl, err := ParseTemplate(`{"text":"{{range .}}{{.Namespace}}/{{.Name}}: {{.Reason}}\n{{end}}"}`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	wc, err := NewWebhookClient(Config{
+		Url:      server.URL,
+		Template: tmpl,
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err := wc.send([]*model.Event{{Name: "a", Namespace: "default", Reason: "BackOff"}}); nil != err {
+		t.Fatal(err)
+	}
+	bodies, _ := rec.requests()
+	if len(bodies) != 1 || string(bodies[0]) != `{"text":"default/a: BackOff\n"}` {
+		t.Fatalf("unexpected body %q", bodies)
+	}
+}
@@ -27,6 +27,10 @@ func registerPrometheusHandler(mux *http.ServeMux, prometheusEndpoint string) er
 	r := prometheus.NewRegistry()
 	r.MustRegister(
 		collector.NewCollector(),
+		collector.NewDeadLetterCollector(),
+		collector.NewStreamCollector(),
+		collector.NewNodeCollector(),
+		collector.NewJobCollector(),
 		prometheus.NewGoCollector(),
 		prometheus.NewProcessCollector(os.Getpid(), ""),
 	)
@@ -14,12 +14,14 @@ type KVObject struct {
 
 type Event struct {
 	Time              time.Time               `json:"time"`
+	UID               string                  `json:"uid,omitempty"`
 	Name              string                  `json:"name,omitempty"`
 	Namespace         string                  `json:"namespace,omitempty"`
 	CreationTimestamp time.Time               `json:"creationTimestamp,omitempty"`
 	Labels            map[int]KVObject        `json:"labels,omitempty"`
 	Annotations       map[int]KVObject        `json:"annotations,omitempty"`
 	Kind              string                  `json:"kind,omitempty"`
+	ObjectName        string                  `json:"objectName,omitempty"`
 	Reason            string                  `json:"reason,omitempty"`
 	Message           string                  `json:"message,omitempty"`
 	FirstTimestamp    time.Time               `json:"firstTimestamp,omitempty"`
@@ -32,15 +34,30 @@ type Event struct {
 	PodCondition      PodCondition            `json:"podCondition,omitempty"`
 	ContainerStatus   map[int]ContainerStatus `json:"containerStatus,omitempty"`
 	PodStatus         string                  `json:"podStatus,omitempty"`
+	Status            string                  `json:"status,omitempty"`
+	NodeCondition     *NodeCondition          `json:"nodeCondition,omitempty"`
+	Rollout           *Rollout                `json:"rollout,omitempty"`
+	Job               *JobStatus              `json:"job,omitempty"`
+	Record            bool                    `json:"record,omitempty"`
 }
 
+// ObjectKey identifies the object an event is about, in the form of
+// namespace/kind/name.
+func (e *Event) ObjectKey() string {
+	return e.Namespace + "/" + e.Kind + "/" + e.ObjectName
+}
+
+// ConvertEvent converts a kubernetes Event record, unlike the events
+// synthesized from the state of objects, it is marked as Record.
 func ConvertEvent(ev *core_v1.Event) *Event {
 	return &Event{
 		Time:              time.Now(),
+		UID:               string(ev.ObjectMeta.UID),
 		Name:              ev.ObjectMeta.Name,
 		Namespace:         ev.ObjectMeta.Namespace,
 		CreationTimestamp: ev.ObjectMeta.CreationTimestamp.Time,
 		Kind:              ev.InvolvedObject.Kind,
+		ObjectName:        ev.InvolvedObject.Name,
 		Reason:            ev.Reason,
 		Message:           ev.Message,
 		FirstTimestamp:    ev.FirstTimestamp.Time,
@@ -50,6 +67,7 @@ func ConvertEvent(ev *core_v1.Event) *Event {
 		Action:            ev.Action,
 		EventTime:         ev.EventTime.Time,
 		Env:               GetEnv(),
+		Record:            true,
 	}
 }
 
@@ -154,12 +172,14 @@ func ConvertPodDeleteEvent(po *core_v1.Pod) *Event {
 func ConvertPodBasicEvent(po *core_v1.Pod) *Event {
 	ev := &Event{
 		Time:              time.Now(),
+		UID:               string(po.ObjectMeta.UID),
 		Name:              po.ObjectMeta.Name,
 		Namespace:         po.ObjectMeta.Namespace,
 		CreationTimestamp: po.ObjectMeta.CreationTimestamp.Time,
 		Labels:            make(map[int]KVObject),
 		Annotations:       make(map[int]KVObject),
 		Kind:              "Pod",
+		ObjectName:        po.ObjectMeta.Name,
 		Env:               GetEnv(),
 		ContainerStatus:   make(map[int]ContainerStatus),
 	}
@@ -0,0 +1,227 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	batch_v1 "k8s.io/api/batch/v1"
+	batch_v1beta1 "k8s.io/api/batch/v1beta1"
+	core_v1 "k8s.io/api/core/v1"
+
+	"github.com/jojohappy/luxun/pkg/util"
+)
+
+const (
+	JobStarted            = "JobStarted"
+	JobCompleted          = "JobCompleted"
+	JobFailed             = "JobFailed"
+	CronJobMissedSchedule = "CronJobMissedSchedule"
+	CronJobSuspended      = "CronJobSuspended"
+	CronJobResumed        = "CronJobResumed"
+
+	maxMissedSchedules = 100
+)
+
+type JobStatus struct {
+	CronJob          string     `json:"cronJob,omitempty"`
+	Active           int32      `json:"active"`
+	Succeeded        int32      `json:"succeeded"`
+	Failed           int32      `json:"failed"`
+	BackoffLimit     int32      `json:"backoffLimit,omitempty"`
+	StartTime        *time.Time `json:"startTime,omitempty"`
+	CompletionTime   *time.Time `json:"completionTime,omitempty"`
+	DurationSeconds  float64    `json:"durationSeconds,omitempty"`
+	Schedule         string     `json:"schedule,omitempty"`
+	Suspended        bool       `json:"suspended,omitempty"`
+	LastScheduleTime *time.Time `json:"lastScheduleTime,omitempty"`
+	MissedSchedule   *time.Time `json:"missedSchedule,omitempty"`
+}
+
+// JobCronJob returns the name of the cron job which created job, if any.
+func JobCronJob(job *batch_v1.Job) string {
+	for _, ref := range job.ObjectMeta.OwnerReferences {
+		if ref.Kind == "CronJob" {
+			return ref.Name
+		}
+	}
+	return ""
+}
+
+// JobFinished returns the condition which finished job, if any.
+func JobFinished(job *batch_v1.Job) *batch_v1.JobCondition {
+	for i := range job.Status.Conditions {
+		c := &job.Status.Conditions[i]
+		if (c.Type == batch_v1.JobComplete || c.Type == batch_v1.JobFailed) && c.Status == core_v1.ConditionTrue {
+			return c
+		}
+	}
+	return nil
+}
+
+func ConvertJobBasicEvent(job *batch_v1.Job) *Event {
+	ev := &Event{
+		Time:              time.Now(),
+		UID:               string(job.ObjectMeta.UID),
+		Name:              job.ObjectMeta.Name,
+		Namespace:         job.ObjectMeta.Namespace,
+		CreationTimestamp: job.ObjectMeta.CreationTimestamp.Time,
+		Labels:            make(map[int]KVObject),
+		Kind:              "Job",
+		ObjectName:        job.ObjectMeta.Name,
+		Type:              core_v1.EventTypeNormal,
+		Env:               GetEnv(),
+		Job: &JobStatus{
+			CronJob:   JobCronJob(job),
+			Active:    job.Status.Active,
+			Succeeded: job.Status.Succeeded,
+			Failed:    job.Status.Failed,
+		},
+	}
+	if nil != job.Spec.BackoffLimit {
+		ev.Job.BackoffLimit = *job.Spec.BackoffLimit
+	}
+	if nil != job.Status.StartTime {
+		ev.Job.StartTime = &job.Status.StartTime.DeepCopy().Time
+	}
+	if nil != job.Status.CompletionTime {
+		ev.Job.CompletionTime = &job.Status.CompletionTime.DeepCopy().Time
+	}
+
+	i := 0
+	for k, v := range job.ObjectMeta.Labels {
+		ev.Labels[i] = KVObject{k, v}
+		i++
+	}
+	return ev
+}
+
+// ConvertJobEvents returns the events of a job starting, completing or
+// failing between two versions of it.
+func ConvertJobEvents(oldJob, newJob *batch_v1.Job) []*Event {
+	events := make([]*Event, 0)
+	if nil == oldJob.Status.StartTime && nil != newJob.Status.StartTime {
+		ev := ConvertJobBasicEvent(newJob)
+		ev.Reason = JobStarted
+		ev.Message = fmt.Sprintf("job %s started", newJob.Name)
+		events = append(events, ev)
+	}
+
+	c := JobFinished(newJob)
+	if nil == c || nil != JobFinished(oldJob) {
+		return events
+	}
+	ev := ConvertJobBasicEvent(newJob)
+	finished := c.LastTransitionTime.Time
+	if nil != ev.Job.CompletionTime {
+		finished = *ev.Job.CompletionTime
+	}
+	if nil != ev.Job.StartTime && !finished.IsZero() {
+		ev.Job.DurationSeconds = finished.Sub(*ev.Job.StartTime).Seconds()
+	}
+	duration := time.Duration(ev.Job.DurationSeconds * float64(time.Second))
+	if c.Type == batch_v1.JobComplete {
+		ev.Reason = JobCompleted
+		ev.Message = fmt.Sprintf("job %s completed in %s", newJob.Name, duration)
+	} else {
+		ev.Reason = JobFailed
+		ev.Type = core_v1.EventTypeWarning
+		ev.Message = fmt.Sprintf("job %s failed after %s with %d failed pods: %s", newJob.Name, duration, newJob.Status.Failed, c.Reason)
+		if c.Message != "" {
+			ev.Message += ": " + c.Message
+		}
+	}
+	return append(events, ev)
+}
+
+func ConvertCronJobBasicEvent(cj *batch_v1beta1.CronJob) *Event {
+	ev := &Event{
+		Time:              time.Now(),
+		UID:               string(cj.ObjectMeta.UID),
+		Name:              cj.ObjectMeta.Name,
+		Namespace:         cj.ObjectMeta.Namespace,
+		CreationTimestamp: cj.ObjectMeta.CreationTimestamp.Time,
+		Labels:            make(map[int]KVObject),
+		Kind:              "CronJob",
+		ObjectName:        cj.ObjectMeta.Name,
+		Type:              core_v1.EventTypeNormal,
+		Env:               GetEnv(),
+		Job: &JobStatus{
+			CronJob:   cj.ObjectMeta.Name,
+			Active:    int32(len(cj.Status.Active)),
+			Schedule:  cj.Spec.Schedule,
+			Suspended: nil != cj.Spec.Suspend && *cj.Spec.Suspend,
+		},
+	}
+	if nil != cj.Status.LastScheduleTime {
+		ev.Job.LastScheduleTime = &cj.Status.LastScheduleTime.DeepCopy().Time
+	}
+
+	i := 0
+	for k, v := range cj.ObjectMeta.Labels {
+		ev.Labels[i] = KVObject{k, v}
+		i++
+	}
+	return ev
+}
+
+// ConvertCronJobEvents returns the events of a cron job being suspended or
+// resumed between two versions of it.
+func ConvertCronJobEvents(oldCronJob, newCronJob *batch_v1beta1.CronJob) []*Event {
+	events := make([]*Event, 0)
+	oldSuspended := nil != oldCronJob.Spec.Suspend && *oldCronJob.Spec.Suspend
+	newSuspended := nil != newCronJob.Spec.Suspend && *newCronJob.Spec.Suspend
+	if oldSuspended == newSuspended {
+		return events
+	}
+	ev := ConvertCronJobBasicEvent(newCronJob)
+	if newSuspended {
+		ev.Reason = CronJobSuspended
+		ev.Message = fmt.Sprintf("cron job %s is suspended", newCronJob.Name)
+	} else {
+		ev.Reason = CronJobResumed
+		ev.Message = fmt.Sprintf("cron job %s is resumed", newCronJob.Name)
+	}
+	return append(events, ev)
+}
+
+// CronJobMissedSchedules returns the last schedule of cj since its last
+// run, and after after, which was not run within grace, or the starting
+// deadline of cj, and the number of such schedules, at most 100 are
+// counted.
+func CronJobMissedSchedules(cj *batch_v1beta1.CronJob, now time.Time, grace time.Duration, after time.Time) (time.Time, int, error) {
+	if nil != cj.Spec.Suspend && *cj.Spec.Suspend {
+		return time.Time{}, 0, nil
+	}
+	schedule, err := util.ParseCron(cj.Spec.Schedule)
+	if nil != err {
+		return time.Time{}, 0, err
+	}
+	if nil != cj.Spec.StartingDeadlineSeconds {
+		grace = time.Duration(*cj.Spec.StartingDeadlineSeconds) * time.Second
+	}
+	last := cj.ObjectMeta.CreationTimestamp.Time
+	if nil != cj.Status.LastScheduleTime {
+		last = cj.Status.LastScheduleTime.Time
+	}
+	if after.After(last) {
+		last = after
+	}
+
+	var missed time.Time
+	count := 0
+	for t := schedule.Next(last); !t.IsZero() && t.Add(grace).Before(now) && count < maxMissedSchedules; t = schedule.Next(t) {
+		missed = t
+		count++
+	}
+	return missed, count, nil
+}
+
+func ConvertCronJobMissedEvent(cj *batch_v1beta1.CronJob, missed time.Time, count int) *Event {
+	ev := ConvertCronJobBasicEvent(cj)
+	ev.Reason = CronJobMissedSchedule
+	ev.Type = core_v1.EventTypeWarning
+	ev.Count = int32(count)
+	ev.Job.MissedSchedule = &missed
+	ev.Message = fmt.Sprintf("cron job %s missed %d schedules, the last at %s", cj.Name, count, missed.Format(time.RFC3339))
+	return ev
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	batch_v1 "k8s.io/api/batch/v1"
+	batch_v1beta1 "k8s.io/api/batch/v1beta1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertJobEvents(t *testing.T) {
+	start := meta_v1.NewTime(time.Date(2018, 3, 1, 2, 0, 0, 0, time.UTC))
+	oldJob := &batch_v1.Job{}
+	oldJob.Name = "nightly-1"
+	oldJob.OwnerReferences = []meta_v1.OwnerReference{{Kind: "CronJob", Name: "nightly"}}
+
+	started := oldJob.DeepCopy()
+	started.Status.StartTime = &start
+	started.Status.Active = 1
+	events := ConvertJobEvents(oldJob, started)
+	if len(events) != 1 || events[0].Reason != JobStarted || events[0].Job.CronJob != "nightly" {
+		t.Fatalf("excepted job started event, got %v", events)
+	}
+	content, _ := json.Marshal(events[0].Job)
+	if !strings.Contains(string(content), `"startTime":"2018-03-01T02:00:00Z"`) || strings.Contains(string(content), "completionTime") {
+		t.Fatalf("excepted only the start time of a running job, got %s", content)
+	}
+
+	failed := started.DeepCopy()
+	failed.Status.Active = 0
+	failed.Status.Failed = 7
+	failed.Status.Conditions = []batch_v1.JobCondition{{
+		Type:               batch_v1.JobFailed,
+		Status:             core_v1.ConditionTrue,
+		Reason:             "BackoffLimitExceeded",
+		Message:            "Job has reached the specified backoff limit",
+		LastTransitionTime: meta_v1.NewTime(start.Add(90 * time.Second)),
+	}}
+	events = ConvertJobEvents(started, failed)
+	if len(events) != 1 || events[0].Reason != JobFailed || events[0].Type != core_v1.EventTypeWarning || events[0].Job.DurationSeconds != 90 {
+		t.Fatalf("excepted job failed event, got %+v", events[0])
+	}
+	if events[0].Message != "job nightly-1 failed after 1m30s with 7 failed pods: BackoffLimitExceeded: Job has reached the specified backoff limit" {
+		t.Fatalf("excepted message of failure, got %s", events[0].Message)
+	}
+	if len(ConvertJobEvents(failed, failed)) != 0 {
+		t.Fatalf("excepted no event of a finished job")
+	}
+}
+
+func TestCronJobMissedSchedules(t *testing.T) {
+	cj := &batch_v1beta1.CronJob{Spec: batch_v1beta1.CronJobSpec{Schedule: "0 * * * *"}}
+	last := meta_v1.NewTime(time.Date(2018, 3, 1, 2, 0, 0, 0, time.UTC))
+	cj.Status.LastScheduleTime = &last
+
+	now := time.Date(2018, 3, 1, 5, 3, 0, 0, time.UTC)
+	missed, count, err := CronJobMissedSchedules(cj, now, 5*time.Minute, time.Time{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if count != 2 || !missed.Equal(time.Date(2018, 3, 1, 4, 0, 0, 0, time.UTC)) {
+		t.Fatalf("excepted 2 missed schedules until 04:00, got %d %v", count, missed)
+	}
+
+	if _, count, _ := CronJobMissedSchedules(cj, now, 5*time.Minute, missed.Add(-time.Hour)); count != 1 {
+		t.Fatalf("excepted 1 missed schedule after 03:00, got %d", count)
+	}
+
+	suspend := true
+	cj.Spec.Suspend = &suspend
+	if _, count, _ := CronJobMissedSchedules(cj, now, 5*time.Minute, time.Time{}); count != 0 {
+		t.Fatalf("excepted no missed schedule of a suspended cron job")
+	}
+}
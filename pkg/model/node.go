@@ -0,0 +1,174 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	core_v1 "k8s.io/api/core/v1"
+)
+
+const (
+	NodeConditionChanged = "NodeConditionChanged"
+	NodeCordoned         = "NodeCordoned"
+	NodeUncordoned       = "NodeUncordoned"
+	NodeTaintAdded       = "NodeTaintAdded"
+	NodeTaintRemoved     = "NodeTaintRemoved"
+	NodeDeleted          = "NodeDeleted"
+)
+
+// NodeConditionTypes are the conditions of nodes whose transitions are
+// recorded. PIDPressure is not known to every version of the api.
+var NodeConditionTypes = []core_v1.NodeConditionType{
+	core_v1.NodeReady,
+	core_v1.NodeMemoryPressure,
+	core_v1.NodeDiskPressure,
+	core_v1.NodeConditionType("PIDPressure"),
+	core_v1.NodeNetworkUnavailable,
+}
+
+type NodeCondition struct {
+	Type           string `json:"type,omitempty"`
+	Status         string `json:"status,omitempty"`
+	PreviousStatus string `json:"previousStatus,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+	Message        string `json:"message,omitempty"`
+}
+
+// NodeConditionHealthy tells if status is the healthy status of the
+// condition, True for Ready and False for the others.
+func NodeConditionHealthy(conditionType, status string) bool {
+	if conditionType == string(core_v1.NodeReady) {
+		return status == string(core_v1.ConditionTrue)
+	}
+	return status == string(core_v1.ConditionFalse)
+}
+
+func ConvertNodeBasicEvent(node *core_v1.Node) *Event {
+	ev := &Event{
+		Time:              time.Now(),
+		UID:               string(node.ObjectMeta.UID),
+		Name:              node.ObjectMeta.Name,
+		CreationTimestamp: node.ObjectMeta.CreationTimestamp.Time,
+		Labels:            make(map[int]KVObject),
+		Kind:              "Node",
+		ObjectName:        node.ObjectMeta.Name,
+		Type:              core_v1.EventTypeNormal,
+		Env:               GetEnv(),
+	}
+
+	i := 0
+	for k, v := range node.ObjectMeta.Labels {
+		ev.Labels[i] = KVObject{k, v}
+		i++
+	}
+	return ev
+}
+
+// ConvertNodeEvents returns an event for every condition transition,
+// cordon, uncordon and taint change between two versions of a node.
+func ConvertNodeEvents(oldNode, newNode *core_v1.Node) []*Event {
+	events := make([]*Event, 0)
+
+	oldConditions := nodeConditions(oldNode)
+	newConditions := nodeConditions(newNode)
+	for _, t := range NodeConditionTypes {
+		c, ok := newConditions[t]
+		if !ok {
+			continue
+		}
+		previous, ok := oldConditions[t]
+		if !ok || previous.Status == c.Status {
+			continue
+		}
+		ev := ConvertNodeBasicEvent(newNode)
+		ev.Reason = NodeConditionChanged
+		ev.Message = fmt.Sprintf("condition %s changed from %s to %s", t, previous.Status, c.Status)
+		if c.Reason != "" {
+			ev.Message += ": " + c.Reason
+		}
+		ev.NodeCondition = &NodeCondition{
+			Type:           string(t),
+			Status:         string(c.Status),
+			PreviousStatus: string(previous.Status),
+			Reason:         c.Reason,
+			Message:        c.Message,
+		}
+		if !NodeConditionHealthy(string(t), string(c.Status)) {
+			ev.Type = core_v1.EventTypeWarning
+		}
+		if !c.LastTransitionTime.IsZero() {
+			ev.EventTime = c.LastTransitionTime.Time
+		}
+		events = append(events, ev)
+	}
+
+	if oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable {
+		ev := ConvertNodeBasicEvent(newNode)
+		if newNode.Spec.Unschedulable {
+			ev.Reason = NodeCordoned
+			ev.Message = fmt.Sprintf("node %s is cordoned", newNode.Name)
+			ev.Type = core_v1.EventTypeWarning
+		} else {
+			ev.Reason = NodeUncordoned
+			ev.Message = fmt.Sprintf("node %s is uncordoned", newNode.Name)
+		}
+		events = append(events, ev)
+	}
+
+	oldTaints := nodeTaints(oldNode)
+	newTaints := nodeTaints(newNode)
+	for _, taint := range newNode.Spec.Taints {
+		if _, ok := oldTaints[taintKey(taint)]; ok {
+			continue
+		}
+		ev := ConvertNodeBasicEvent(newNode)
+		ev.Reason = NodeTaintAdded
+		ev.Message = fmt.Sprintf("taint %s is added", taintKey(taint))
+		if taint.Effect == core_v1.TaintEffectNoExecute || taint.Effect == core_v1.TaintEffectNoSchedule {
+			ev.Type = core_v1.EventTypeWarning
+		}
+		events = append(events, ev)
+	}
+	for _, taint := range oldNode.Spec.Taints {
+		if _, ok := newTaints[taintKey(taint)]; ok {
+			continue
+		}
+		ev := ConvertNodeBasicEvent(newNode)
+		ev.Reason = NodeTaintRemoved
+		ev.Message = fmt.Sprintf("taint %s is removed", taintKey(taint))
+		events = append(events, ev)
+	}
+	return events
+}
+
+func ConvertNodeDeleteEvent(node *core_v1.Node) *Event {
+	ev := ConvertNodeBasicEvent(node)
+	ev.Action = "Delete"
+	ev.Reason = NodeDeleted
+	ev.Message = fmt.Sprintf("node %s is deleted", node.Name)
+	ev.Type = core_v1.EventTypeWarning
+	return ev
+}
+
+func nodeConditions(node *core_v1.Node) map[core_v1.NodeConditionType]core_v1.NodeCondition {
+	conditions := make(map[core_v1.NodeConditionType]core_v1.NodeCondition, len(node.Status.Conditions))
+	for _, c := range node.Status.Conditions {
+		conditions[c.Type] = c
+	}
+	return conditions
+}
+
+func nodeTaints(node *core_v1.Node) map[string]struct{} {
+	taints := make(map[string]struct{}, len(node.Spec.Taints))
+	for _, taint := range node.Spec.Taints {
+		taints[taintKey(taint)] = struct{}{}
+	}
+	return taints
+}
+
+func taintKey(taint core_v1.Taint) string {
+	if taint.Value == "" {
+		return fmt.Sprintf("%s:%s", taint.Key, taint.Effect)
+	}
+	return fmt.Sprintf("%s=%s:%s", taint.Key, taint.Value, taint.Effect)
+}
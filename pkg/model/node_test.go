@@ -0,0 +1,53 @@
+package model
+
+import (
+	"testing"
+
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func TestConvertNodeEvents(t *testing.T) {
+	oldNode := &core_v1.Node{
+		Spec: core_v1.NodeSpec{
+			Taints: []core_v1.Taint{{Key: "dedicated", Value: "db", Effect: core_v1.TaintEffectNoSchedule}},
+		},
+		Status: core_v1.NodeStatus{
+			Conditions: []core_v1.NodeCondition{
+				{Type: core_v1.NodeReady, Status: core_v1.ConditionTrue},
+				{Type: core_v1.NodeDiskPressure, Status: core_v1.ConditionFalse},
+			},
+		},
+	}
+	oldNode.Name = "node-1"
+	newNode := oldNode.DeepCopy()
+	newNode.Spec.Unschedulable = true
+	newNode.Spec.Taints = []core_v1.Taint{{Key: "node.kubernetes.io/unreachable", Effect: core_v1.TaintEffectNoExecute}}
+	newNode.Status.Conditions[0].Status = core_v1.ConditionUnknown
+	newNode.Status.Conditions[0].Reason = "NodeStatusUnknown"
+
+	events := ConvertNodeEvents(oldNode, newNode)
+	if len(events) != 4 {
+		t.Fatalf("excepted 4 events, got %d", len(events))
+	}
+	ev := events[0]
+	if ev.Reason != NodeConditionChanged || ev.Type != core_v1.EventTypeWarning || ev.NodeCondition.Type != "Ready" ||
+		ev.NodeCondition.PreviousStatus != "True" || ev.NodeCondition.Status != "Unknown" {
+		t.Fatalf("excepted Ready transition, got %+v %+v", ev, ev.NodeCondition)
+	}
+	if ev.Message != "condition Ready changed from True to Unknown: NodeStatusUnknown" {
+		t.Fatalf("excepted message of transition, got %s", ev.Message)
+	}
+	if events[1].Reason != NodeCordoned || events[1].Kind != "Node" || events[1].ObjectName != "node-1" {
+		t.Fatalf("excepted cordon event, got %+v", events[1])
+	}
+	if events[2].Reason != NodeTaintAdded || events[2].Message != "taint node.kubernetes.io/unreachable:NoExecute is added" {
+		t.Fatalf("excepted taint added event, got %+v", events[2])
+	}
+	if events[3].Reason != NodeTaintRemoved || events[3].Message != "taint dedicated=db:NoSchedule is removed" {
+		t.Fatalf("excepted taint removed event, got %+v", events[3])
+	}
+
+	if len(ConvertNodeEvents(newNode, newNode)) != 0 {
+		t.Fatalf("excepted no event without changes")
+	}
+}
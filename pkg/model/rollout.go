@@ -0,0 +1,106 @@
+package model
+
+import (
+	"sync"
+)
+
+// rolloutHistorySize is the number of pod templates remembered per
+// workload to tell a rollback from a new rollout.
+const rolloutHistorySize = 10
+
+type rolloutState struct {
+	templates []string
+	rolling   bool
+	previous  string
+}
+
+func (s *rolloutState) seen(template string) bool {
+	for _, t := range s.templates {
+		if t == template {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *rolloutState) push(template string) {
+	s.templates = append(s.templates, template)
+	if len(s.templates) > rolloutHistorySize {
+		s.templates = s.templates[len(s.templates)-rolloutHistorySize:]
+	}
+}
+
+// RolloutTracker turns the updates of workloads into rollout events. A
+// rollout starts when the pod template changes, it is a rollback if the
+// template was seen before, and it lasts until every replica is updated
+// and available.
+type RolloutTracker struct {
+	lock     sync.Mutex
+	rollouts map[string]*rolloutState
+}
+
+func NewRolloutTracker() *RolloutTracker {
+	return &RolloutTracker{
+		rollouts: make(map[string]*rolloutState),
+	}
+}
+
+// Add records a workload seen for the first time, a rollout in progress is
+// tracked until it completes.
+func (t *RolloutTracker) Add(ws WorkloadStatus) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.rollouts[ws.Key()] = &rolloutState{
+		templates: []string{ws.Template},
+		rolling:   !ws.Complete(),
+		previous:  ws.Revision,
+	}
+}
+
+func (t *RolloutTracker) Update(old, ws WorkloadStatus) []*Event {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	state, ok := t.rollouts[ws.Key()]
+	if !ok {
+		state = &rolloutState{templates: []string{old.Template}, previous: old.Revision}
+		t.rollouts[ws.Key()] = state
+	}
+
+	events := make([]*Event, 0)
+	if ws.Template != old.Template {
+		reason := RolloutStarted
+		if state.seen(ws.Template) {
+			reason = RolloutRolledBack
+		}
+		state.push(ws.Template)
+		state.rolling = true
+		state.previous = old.Revision
+		// the revision may be bumped by a later update
+		started := ws
+		if started.Revision == old.Revision {
+			started.Revision = ""
+		}
+		return append(events, ConvertRolloutEvent(started, reason, state.previous))
+	}
+	if !state.rolling {
+		return events
+	}
+
+	switch {
+	case ws.Complete():
+		state.rolling = false
+		events = append(events, ConvertRolloutEvent(ws, RolloutCompleted, state.previous))
+		state.previous = ws.Revision
+	case ws.Stalled && !old.Stalled:
+		events = append(events, ConvertRolloutEvent(ws, RolloutStalled, state.previous))
+	case ws.UpdatedReplicas != old.UpdatedReplicas || ws.ReadyReplicas != old.ReadyReplicas || ws.AvailableReplicas != old.AvailableReplicas:
+		events = append(events, ConvertRolloutEvent(ws, RolloutProgressing, state.previous))
+	}
+	return events
+}
+
+func (t *RolloutTracker) Delete(ws WorkloadStatus) {
+	t.lock.Lock()
+	delete(t.rollouts, ws.Key())
+	t.lock.Unlock()
+}
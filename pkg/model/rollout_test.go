@@ -0,0 +1,86 @@
+package model
+
+import (
+	"testing"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func TestRolloutTracker(t *testing.T) {
+	replicas := int32(2)
+	d := &apps_v1.Deployment{
+		Spec: apps_v1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: core_v1.PodTemplateSpec{
+				Spec: core_v1.PodSpec{Containers: []core_v1.Container{{Name: "web", Image: "web:1"}}},
+			},
+		},
+		Status: apps_v1.DeploymentStatus{UpdatedReplicas: 2, ReadyReplicas: 2, AvailableReplicas: 2},
+	}
+	d.Name, d.Namespace = "web", "prod"
+	d.Annotations = map[string]string{deploymentRevisionAnnotation: "1"}
+
+	tracker := NewRolloutTracker()
+	v1 := ConvertDeploymentStatus(d)
+	tracker.Add(v1)
+
+	reasons := func(events []*Event) []string {
+		r := make([]string, 0)
+		for _, ev := range events {
+			r = append(r, ev.Reason)
+		}
+		return r
+	}
+	expect := func(events []*Event, excepted ...string) {
+		got := reasons(events)
+		if len(got) != len(excepted) {
+			t.Fatalf("excepted %v, got %v", excepted, got)
+		}
+		for i := range got {
+			if got[i] != excepted[i] {
+				t.Fatalf("excepted %v, got %v", excepted, got)
+			}
+		}
+	}
+
+	// new template
+	d2 := d.DeepCopy()
+	d2.Generation = 2
+	d2.Spec.Template.Spec.Containers[0].Image = "web:2"
+	v2 := ConvertDeploymentStatus(d2)
+	expect(tracker.Update(v1, v2), RolloutStarted)
+
+	d3 := d2.DeepCopy()
+	d3.Annotations[deploymentRevisionAnnotation] = "2"
+	d3.Status = apps_v1.DeploymentStatus{ObservedGeneration: 2, UpdatedReplicas: 1, ReadyReplicas: 2, AvailableReplicas: 2}
+	v3 := ConvertDeploymentStatus(d3)
+	expect(tracker.Update(v2, v3), RolloutProgressing)
+
+	d4 := d3.DeepCopy()
+	d4.Status.Conditions = []apps_v1.DeploymentCondition{{Type: apps_v1.DeploymentProgressing, Status: core_v1.ConditionFalse, Reason: "ProgressDeadlineExceeded"}}
+	v4 := ConvertDeploymentStatus(d4)
+	expect(tracker.Update(v3, v4), RolloutStalled)
+
+	d5 := d4.DeepCopy()
+	d5.Status = apps_v1.DeploymentStatus{ObservedGeneration: 2, UpdatedReplicas: 2, ReadyReplicas: 2, AvailableReplicas: 2}
+	v5 := ConvertDeploymentStatus(d5)
+	events := tracker.Update(v4, v5)
+	expect(events, RolloutCompleted)
+	if events[0].Rollout.Revision != "2" || events[0].Rollout.PreviousRevision != "1" || events[0].Rollout.Replicas != 2 {
+		t.Fatalf("excepted revisions and replicas of the rollout, got %+v", events[0].Rollout)
+	}
+
+	// no more events once completed
+	expect(tracker.Update(v5, v5))
+
+	// back to the first template
+	d6 := d5.DeepCopy()
+	d6.Generation = 3
+	d6.Spec.Template.Spec.Containers[0].Image = "web:1"
+	events = tracker.Update(v5, ConvertDeploymentStatus(d6))
+	expect(events, RolloutRolledBack)
+	if events[0].Type != core_v1.EventTypeWarning || events[0].Rollout.PreviousRevision != "2" {
+		t.Fatalf("excepted warning of rollback from revision 2, got %+v", events[0])
+	}
+}
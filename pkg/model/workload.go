@@ -0,0 +1,194 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+const (
+	RolloutStarted     = "RolloutStarted"
+	RolloutProgressing = "RolloutProgressing"
+	RolloutStalled     = "RolloutStalled"
+	RolloutCompleted   = "RolloutCompleted"
+	RolloutRolledBack  = "RolloutRolledBack"
+
+	deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+)
+
+type Rollout struct {
+	Revision          string `json:"revision,omitempty"`
+	PreviousRevision  string `json:"previousRevision,omitempty"`
+	Replicas          int32  `json:"replicas"`
+	UpdatedReplicas   int32  `json:"updatedReplicas"`
+	ReadyReplicas     int32  `json:"readyReplicas"`
+	AvailableReplicas int32  `json:"availableReplicas"`
+	Generation        int64  `json:"generation,omitempty"`
+}
+
+// WorkloadStatus is the rollout status common to deployments, stateful
+// sets and daemon sets. Revision is the revision of a deployment or the
+// update revision of a stateful set, daemon sets do not report theirs.
+type WorkloadStatus struct {
+	Kind              string
+	UID               string
+	Name              string
+	Namespace         string
+	CreationTimestamp time.Time
+	Labels            map[string]string
+	Template          string
+	Revision          string
+	Generation        int64
+	Replicas          int32
+	UpdatedReplicas   int32
+	ReadyReplicas     int32
+	AvailableReplicas int32
+	Observed          bool
+	Stalled           bool
+}
+
+// Key identifies the workload, in the form of namespace/kind/name.
+func (ws *WorkloadStatus) Key() string {
+	return ws.Namespace + "/" + ws.Kind + "/" + ws.Name
+}
+
+// Complete tells if every replica runs the current template.
+func (ws *WorkloadStatus) Complete() bool {
+	return ws.Observed && ws.UpdatedReplicas == ws.Replicas && ws.AvailableReplicas == ws.Replicas
+}
+
+func ConvertDeploymentStatus(d *apps_v1.Deployment) WorkloadStatus {
+	replicas := int32(1)
+	if nil != d.Spec.Replicas {
+		replicas = *d.Spec.Replicas
+	}
+	ws := WorkloadStatus{
+		Kind:              "Deployment",
+		UID:               string(d.ObjectMeta.UID),
+		Name:              d.ObjectMeta.Name,
+		Namespace:         d.ObjectMeta.Namespace,
+		CreationTimestamp: d.ObjectMeta.CreationTimestamp.Time,
+		Labels:            d.ObjectMeta.Labels,
+		Template:          templateHash(&d.Spec.Template),
+		Revision:          d.ObjectMeta.Annotations[deploymentRevisionAnnotation],
+		Generation:        d.ObjectMeta.Generation,
+		Replicas:          replicas,
+		UpdatedReplicas:   d.Status.UpdatedReplicas,
+		ReadyReplicas:     d.Status.ReadyReplicas,
+		AvailableReplicas: d.Status.AvailableReplicas,
+		Observed:          d.Status.ObservedGeneration >= d.ObjectMeta.Generation,
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == apps_v1.DeploymentProgressing && c.Status == core_v1.ConditionFalse && c.Reason == "ProgressDeadlineExceeded" {
+			ws.Stalled = true
+		}
+	}
+	return ws
+}
+
+func ConvertStatefulSetStatus(s *apps_v1.StatefulSet) WorkloadStatus {
+	replicas := int32(1)
+	if nil != s.Spec.Replicas {
+		replicas = *s.Spec.Replicas
+	}
+	return WorkloadStatus{
+		Kind:              "StatefulSet",
+		UID:               string(s.ObjectMeta.UID),
+		Name:              s.ObjectMeta.Name,
+		Namespace:         s.ObjectMeta.Namespace,
+		CreationTimestamp: s.ObjectMeta.CreationTimestamp.Time,
+		Labels:            s.ObjectMeta.Labels,
+		Template:          templateHash(&s.Spec.Template),
+		Revision:          s.Status.UpdateRevision,
+		Generation:        s.ObjectMeta.Generation,
+		Replicas:          replicas,
+		UpdatedReplicas:   s.Status.UpdatedReplicas,
+		ReadyReplicas:     s.Status.ReadyReplicas,
+		// stateful sets do not report available replicas
+		AvailableReplicas: s.Status.ReadyReplicas,
+		Observed:          s.Status.ObservedGeneration >= s.ObjectMeta.Generation,
+	}
+}
+
+func ConvertDaemonSetStatus(d *apps_v1.DaemonSet) WorkloadStatus {
+	return WorkloadStatus{
+		Kind:              "DaemonSet",
+		UID:               string(d.ObjectMeta.UID),
+		Name:              d.ObjectMeta.Name,
+		Namespace:         d.ObjectMeta.Namespace,
+		CreationTimestamp: d.ObjectMeta.CreationTimestamp.Time,
+		Labels:            d.ObjectMeta.Labels,
+		Template:          templateHash(&d.Spec.Template),
+		Generation:        d.ObjectMeta.Generation,
+		Replicas:          d.Status.DesiredNumberScheduled,
+		UpdatedReplicas:   d.Status.UpdatedNumberScheduled,
+		ReadyReplicas:     d.Status.NumberReady,
+		AvailableReplicas: d.Status.NumberAvailable,
+		Observed:          d.Status.ObservedGeneration >= d.ObjectMeta.Generation,
+	}
+}
+
+func templateHash(template *core_v1.PodTemplateSpec) string {
+	content, err := json.Marshal(template)
+	if nil != err {
+		return ""
+	}
+	h := fnv.New64a()
+	h.Write(content)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// ConvertRolloutEvent returns an event of the rollout of ws.
+func ConvertRolloutEvent(ws WorkloadStatus, reason, previousRevision string) *Event {
+	ev := &Event{
+		Time:              time.Now(),
+		UID:               ws.UID,
+		Name:              ws.Name,
+		Namespace:         ws.Namespace,
+		CreationTimestamp: ws.CreationTimestamp,
+		Labels:            make(map[int]KVObject),
+		Kind:              ws.Kind,
+		ObjectName:        ws.Name,
+		Reason:            reason,
+		Type:              core_v1.EventTypeNormal,
+		Env:               GetEnv(),
+		Rollout: &Rollout{
+			Revision:          ws.Revision,
+			PreviousRevision:  previousRevision,
+			Replicas:          ws.Replicas,
+			UpdatedReplicas:   ws.UpdatedReplicas,
+			ReadyReplicas:     ws.ReadyReplicas,
+			AvailableReplicas: ws.AvailableReplicas,
+			Generation:        ws.Generation,
+		},
+	}
+	if reason == RolloutStalled || reason == RolloutRolledBack {
+		ev.Type = core_v1.EventTypeWarning
+	}
+
+	i := 0
+	for k, v := range ws.Labels {
+		ev.Labels[i] = KVObject{k, v}
+		i++
+	}
+
+	progress := fmt.Sprintf("%d/%d updated, %d ready, %d available", ws.UpdatedReplicas, ws.Replicas, ws.ReadyReplicas, ws.AvailableReplicas)
+	switch reason {
+	case RolloutStarted:
+		ev.Message = fmt.Sprintf("rollout of %s %s started from revision %s", ws.Kind, ws.Name, previousRevision)
+	case RolloutRolledBack:
+		ev.Message = fmt.Sprintf("%s %s is rolling back from revision %s to a previous template", ws.Kind, ws.Name, previousRevision)
+	case RolloutProgressing:
+		ev.Message = fmt.Sprintf("rollout of %s %s is progressing: %s", ws.Kind, ws.Name, progress)
+	case RolloutStalled:
+		ev.Message = fmt.Sprintf("rollout of %s %s exceeded its progress deadline: %s", ws.Kind, ws.Name, progress)
+	case RolloutCompleted:
+		ev.Message = fmt.Sprintf("rollout of %s %s to revision %s completed: %s", ws.Kind, ws.Name, ws.Revision, progress)
+	}
+	return ev
+}
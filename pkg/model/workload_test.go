@@ -0,0 +1,149 @@
+package model
+
+import (
+	"testing"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertDeploymentStatus(t *testing.T) {
+	replicas := int32(3)
+	stalled := apps_v1.DeploymentCondition{
+		Type:   apps_v1.DeploymentProgressing,
+		Status: core_v1.ConditionFalse,
+		Reason: "ProgressDeadlineExceeded",
+	}
+	cases := []struct {
+		name       string
+		generation int64
+		status     apps_v1.DeploymentStatus
+		observed   bool
+		stalled    bool
+		complete   bool
+	}{
+		{
+			name:       "complete",
+			generation: 2,
+			status:     apps_v1.DeploymentStatus{ObservedGeneration: 2, UpdatedReplicas: 3, ReadyReplicas: 3, AvailableReplicas: 3},
+			observed:   true,
+			complete:   true,
+		},
+		{
+			// the status still describes the previous spec
+			name:       "not observed",
+			generation: 3,
+			status:     apps_v1.DeploymentStatus{ObservedGeneration: 2, UpdatedReplicas: 3, ReadyReplicas: 3, AvailableReplicas: 3},
+		},
+		{
+			name:       "stalled",
+			generation: 2,
+			status: apps_v1.DeploymentStatus{
+				ObservedGeneration: 2,
+				UpdatedReplicas:    1,
+				ReadyReplicas:      2,
+				AvailableReplicas:  2,
+				Conditions:         []apps_v1.DeploymentCondition{stalled},
+			},
+			observed: true,
+			stalled:  true,
+		},
+		{
+			name:       "progressing",
+			generation: 2,
+			status: apps_v1.DeploymentStatus{
+				ObservedGeneration: 2,
+				UpdatedReplicas:    1,
+				Conditions: []apps_v1.DeploymentCondition{
+					{Type: apps_v1.DeploymentProgressing, Status: core_v1.ConditionTrue, Reason: "ReplicaSetUpdated"},
+				},
+			},
+			observed: true,
+		},
+	}
+	for _, c := range cases {
+		d := &apps_v1.Deployment{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:        "web",
+				Namespace:   "prod",
+				Generation:  c.generation,
+				Annotations: map[string]string{deploymentRevisionAnnotation: "4"},
+			},
+			Spec:   apps_v1.DeploymentSpec{Replicas: &replicas},
+			Status: c.status,
+		}
+		ws := ConvertDeploymentStatus(d)
+		if ws.Observed != c.observed || ws.Stalled != c.stalled || ws.Complete() != c.complete {
+			t.Fatalf("%s: excepted observed %v, stalled %v and complete %v, got %+v", c.name, c.observed, c.stalled, c.complete, ws)
+		}
+		if ws.Revision != "4" || ws.Generation != c.generation || ws.Replicas != 3 {
+			t.Fatalf("%s: excepted revision 4 of generation %d with 3 replicas, got %+v", c.name, c.generation, ws)
+		}
+	}
+
+	// replicas default to 1
+	if ws := ConvertDeploymentStatus(&apps_v1.Deployment{}); ws.Replicas != 1 {
+		t.Fatalf("excepted 1 replica by default, got %d", ws.Replicas)
+	}
+}
+
+func TestConvertStatefulSetStatus(t *testing.T) {
+	replicas := int32(3)
+	s := &apps_v1.StatefulSet{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "db", Namespace: "prod", Generation: 5},
+		Spec:       apps_v1.StatefulSetSpec{Replicas: &replicas},
+		Status: apps_v1.StatefulSetStatus{
+			ObservedGeneration: 5,
+			UpdateRevision:     "db-7b9c5d",
+			UpdatedReplicas:    3,
+			ReadyReplicas:      2,
+		},
+	}
+	ws := ConvertStatefulSetStatus(s)
+	if ws.Revision != "db-7b9c5d" || ws.Generation != 5 || !ws.Observed {
+		t.Fatalf("excepted observed update revision of generation 5, got %+v", ws)
+	}
+	// ready replicas stand in for the available ones
+	if ws.AvailableReplicas != 2 || ws.Complete() {
+		t.Fatalf("excepted 2 available replicas of an incomplete rollout, got %+v", ws)
+	}
+
+	s.Status.ReadyReplicas = 3
+	if ws := ConvertStatefulSetStatus(s); !ws.Complete() {
+		t.Fatalf("excepted complete rollout once every replica is ready, got %+v", ws)
+	}
+	s.Generation = 6
+	if ws := ConvertStatefulSetStatus(s); ws.Observed || ws.Complete() {
+		t.Fatalf("excepted unobserved spec not to be complete, got %+v", ws)
+	}
+}
+
+func TestConvertDaemonSetStatus(t *testing.T) {
+	d := &apps_v1.DaemonSet{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "agent", Namespace: "kube-system", Generation: 7},
+		Status: apps_v1.DaemonSetStatus{
+			ObservedGeneration:     7,
+			DesiredNumberScheduled: 4,
+			UpdatedNumberScheduled: 4,
+			NumberReady:            4,
+			NumberAvailable:        3,
+		},
+	}
+	ws := ConvertDaemonSetStatus(d)
+	if ws.Revision != "" || ws.Generation != 7 {
+		t.Fatalf("excepted generation 7 without a revision, got %+v", ws)
+	}
+	if ws.Replicas != 4 || ws.AvailableReplicas != 3 || !ws.Observed || ws.Complete() {
+		t.Fatalf("excepted an observed rollout waiting for 1 available pod, got %+v", ws)
+	}
+
+	d.Status.NumberAvailable = 4
+	if ws := ConvertDaemonSetStatus(d); !ws.Complete() {
+		t.Fatalf("excepted complete rollout, got %+v", ws)
+	}
+	d.Status.ObservedGeneration = 6
+	if ws := ConvertDaemonSetStatus(d); ws.Observed || ws.Complete() {
+		t.Fatalf("excepted unobserved spec not to be complete, got %+v", ws)
+	}
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"sync"
+)
+
+// JobKey identifies all the jobs of a cron job, or with an empty name all
+// the jobs of a namespace not created by a cron job.
+type JobKey struct {
+	Namespace string
+	Name      string
+}
+
+// JobResult is the number of jobs finished with a status.
+type JobResult struct {
+	JobKey
+	Status string
+}
+
+type JobStorage struct {
+	lock      sync.RWMutex
+	results   map[JobResult]float64
+	durations map[JobKey]float64
+	missed    map[JobKey]float64
+	suspended map[JobKey]bool
+}
+
+func (m *JobStorage) AddResult(key JobKey, status string, duration float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.results[JobResult{key, status}]++
+	m.durations[key] = duration
+}
+
+func (m *JobStorage) AddMissed(key JobKey, count int) {
+	m.lock.Lock()
+	m.missed[key] += float64(count)
+	m.lock.Unlock()
+}
+
+func (m *JobStorage) SetSuspended(key JobKey, suspended bool) {
+	m.lock.Lock()
+	m.suspended[key] = suspended
+	m.lock.Unlock()
+}
+
+// RemoveCronJob forgets everything about a deleted cron job and its jobs.
+func (m *JobStorage) RemoveCronJob(key JobKey) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for r := range m.results {
+		if r.JobKey == key {
+			delete(m.results, r)
+		}
+	}
+	delete(m.durations, key)
+	delete(m.missed, key)
+	delete(m.suspended, key)
+}
+
+func (m *JobStorage) Results() map[JobResult]float64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	results := make(map[JobResult]float64, len(m.results))
+	for k, v := range m.results {
+		results[k] = v
+	}
+	return results
+}
+
+func (m *JobStorage) Durations() map[JobKey]float64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	durations := make(map[JobKey]float64, len(m.durations))
+	for k, v := range m.durations {
+		durations[k] = v
+	}
+	return durations
+}
+
+func (m *JobStorage) Missed() map[JobKey]float64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	missed := make(map[JobKey]float64, len(m.missed))
+	for k, v := range m.missed {
+		missed[k] = v
+	}
+	return missed
+}
+
+func (m *JobStorage) Suspended() map[JobKey]bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	suspended := make(map[JobKey]bool, len(m.suspended))
+	for k, v := range m.suspended {
+		suspended[k] = v
+	}
+	return suspended
+}
+
+func NewJobStorage() *JobStorage {
+	return &JobStorage{
+		results:   make(map[JobResult]float64),
+		durations: make(map[JobKey]float64),
+		missed:    make(map[JobKey]float64),
+		suspended: make(map[JobKey]bool),
+	}
+}
+
+var jobStorage *JobStorage
+var jobOnce sync.Once
+
+func JobStorageInst() *JobStorage {
+	jobOnce.Do(func() {
+		jobStorage = NewJobStorage()
+	})
+	return jobStorage
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"sync"
+)
+
+// NodeStatus is the last known status of a node.
+type NodeStatus struct {
+	Conditions    map[string]string
+	Unschedulable bool
+	Taints        int
+}
+
+// NodeTransition is a transition of a node condition to a status.
+type NodeTransition struct {
+	Condition string
+	Status    string
+}
+
+type NodeStorage struct {
+	lock        sync.RWMutex
+	nodes       map[string]NodeStatus
+	transitions map[NodeTransition]float64
+}
+
+func (m *NodeStorage) Set(name string, status NodeStatus) {
+	m.lock.Lock()
+	m.nodes[name] = status
+	m.lock.Unlock()
+}
+
+func (m *NodeStorage) Remove(name string) {
+	m.lock.Lock()
+	delete(m.nodes, name)
+	m.lock.Unlock()
+}
+
+func (m *NodeStorage) GetAll() map[string]NodeStatus {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	nodes := make(map[string]NodeStatus, len(m.nodes))
+	for name, status := range m.nodes {
+		nodes[name] = status
+	}
+	return nodes
+}
+
+func (m *NodeStorage) AddTransition(condition, status string) {
+	m.lock.Lock()
+	m.transitions[NodeTransition{condition, status}]++
+	m.lock.Unlock()
+}
+
+func (m *NodeStorage) Transitions() map[NodeTransition]float64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	transitions := make(map[NodeTransition]float64, len(m.transitions))
+	for t, count := range m.transitions {
+		transitions[t] = count
+	}
+	return transitions
+}
+
+func NewNodeStorage() *NodeStorage {
+	return &NodeStorage{
+		nodes:       make(map[string]NodeStatus),
+		transitions: make(map[NodeTransition]float64),
+	}
+}
+
+var nodeStorage *NodeStorage
+var nodeOnce sync.Once
+
+func NodeStorageInst() *NodeStorage {
+	nodeOnce.Do(func() {
+		nodeStorage = NewNodeStorage()
+	})
+	return nodeStorage
+}
@@ -0,0 +1,158 @@
+package stream
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+const (
+	defaultDedupWindow     = 30 * time.Second
+	defaultDedupMaxEntries = 10000
+)
+
+// DedupConfig are the params of the dedup operator, e.g.
+//
+//	operators:
+//	- name: dedup
+//	  params:
+//	    window: 1m
+//	    maxEntries: 10000
+//
+// Events with the same fingerprint, the involved object, reason, message
+// and pod status, are held back for window since the first of them and
+// then emitted as one record. The record is the last event with the first
+// and last timestamps of all of them and the number of occurrences as
+// count. Once maxEntries fingerprints are held, the oldest is emitted
+// early.
+type DedupConfig struct {
+	Window     time.Duration `yaml:"window"`
+	MaxEntries int           `yaml:"maxEntries"`
+}
+
+type dedupEntry struct {
+	ev        *model.Event
+	firstSeen time.Time
+	first     time.Time
+	last      time.Time
+	count     int32
+}
+
+type dedup struct {
+	window     time.Duration
+	maxEntries int
+	entries    map[string]*dedupEntry
+	now        func() time.Time
+}
+
+func newDedup(params Params) (*Operator, error) {
+	config := DedupConfig{
+		Window:     defaultDedupWindow,
+		MaxEntries: defaultDedupMaxEntries,
+	}
+	if err := params.Decode(&config); nil != err {
+		return nil, err
+	}
+	if config.Window <= 0 {
+		return nil, fmt.Errorf("dedup window must be positive")
+	}
+	if config.MaxEntries <= 0 {
+		return nil, fmt.Errorf("dedup maxEntries must be positive")
+	}
+	d := &dedup{
+		window:     config.Window,
+		maxEntries: config.MaxEntries,
+		entries:    make(map[string]*dedupEntry),
+		now:        clock,
+	}
+	op := NewOperator(d.add)
+	interval := time.Second
+	if d.window < interval {
+		interval = d.window
+	}
+	op.SetFlush(interval, d.flush)
+	return op, nil
+}
+
+func fingerprint(en *model.Event) string {
+	return en.ObjectKey() + "\x00" + en.Reason + "\x00" + en.Message + "\x00" + en.PodStatus
+}
+
+// add holds en back, it emits the oldest entry if there are too many.
+func (d *dedup) add(en *model.Event) (*model.Event, error) {
+	first, last := en.FirstTimestamp, en.LastTimestamp
+	if first.IsZero() {
+		first = en.Time
+	}
+	if last.IsZero() {
+		last = en.Time
+	}
+
+	key := fingerprint(en)
+	if e, ok := d.entries[key]; ok {
+		e.ev = en
+		e.count++
+		if first.Before(e.first) {
+			e.first = first
+		}
+		if last.After(e.last) {
+			e.last = last
+		}
+		return nil, nil
+	}
+
+	var evicted *model.Event
+	if len(d.entries) >= d.maxEntries {
+		var oldestKey string
+		var oldest *dedupEntry
+		for k, e := range d.entries {
+			if nil == oldest || e.firstSeen.Before(oldest.firstSeen) {
+				oldestKey, oldest = k, e
+			}
+		}
+		delete(d.entries, oldestKey)
+		evicted = oldest.aggregate()
+	}
+	d.entries[key] = &dedupEntry{
+		ev:        en,
+		firstSeen: d.now(),
+		first:     first,
+		last:      last,
+		count:     1,
+	}
+	return evicted, nil
+}
+
+// flush emits the entries whose window has passed, or all of them if
+// final, oldest first.
+func (d *dedup) flush(final bool) []*model.Event {
+	now := d.now()
+	expired := make([]*dedupEntry, 0)
+	for k, e := range d.entries {
+		if final || now.Sub(e.firstSeen) >= d.window {
+			expired = append(expired, e)
+			delete(d.entries, k)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].firstSeen.Before(expired[j].firstSeen) })
+	events := make([]*model.Event, 0, len(expired))
+	for _, e := range expired {
+		events = append(events, e.aggregate())
+	}
+	return events
+}
+
+// aggregate returns the last event with the timestamps and count of all
+// the events of the entry. Kubernetes counts the occurrences of an event
+// itself, the larger of both counts is kept.
+func (e *dedupEntry) aggregate() *model.Event {
+	ev := *e.ev
+	ev.FirstTimestamp = e.first
+	ev.LastTimestamp = e.last
+	if e.count > ev.Count {
+		ev.Count = e.count
+	}
+	return &ev
+}
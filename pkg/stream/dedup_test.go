@@ -0,0 +1,57 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestDedup(t *testing.T) {
+	now := time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)
+	clock = func() time.Time { return now }
+	defer func() { clock = time.Now }()
+	op, err := newDedup(Params{"window": "1m", "maxEntries": 2})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if nil == op.flushFn {
+		t.Fatalf("excepted dedup to flush")
+	}
+
+	newEvent := func(name, reason string, at time.Time) *model.Event {
+		return &model.Event{Namespace: "default", Kind: "Pod", ObjectName: name, Reason: reason, Message: "Back-off restarting failed container", Time: at}
+	}
+	for i := 0; i < 3; i++ {
+		if ev, _ := op.fn(newEvent("web", "BackOff", now.Add(time.Duration(i)*time.Second))); nil != ev {
+			t.Fatalf("excepted duplicated event to be held back")
+		}
+	}
+	if events := op.flushFn(false); len(events) != 0 {
+		t.Fatalf("excepted no event before the window passes, got %d", len(events))
+	}
+
+	now = now.Add(time.Minute)
+	events := op.flushFn(false)
+	if len(events) != 1 {
+		t.Fatalf("excepted 1 aggregated event, got %d", len(events))
+	}
+	ev := events[0]
+	if ev.Count != 3 || !ev.FirstTimestamp.Equal(now.Add(-time.Minute)) || !ev.LastTimestamp.Equal(now.Add(-time.Minute+2*time.Second)) {
+		t.Fatalf("excepted aggregated count and timestamps, got %d %v %v", ev.Count, ev.FirstTimestamp, ev.LastTimestamp)
+	}
+
+	op.fn(newEvent("web", "BackOff", now))
+	now = now.Add(time.Second)
+	op.fn(newEvent("web", "Pulled", now))
+	if ev, _ := op.fn(newEvent("db", "BackOff", now)); nil == ev || ev.Reason != "BackOff" || ev.ObjectName != "web" {
+		t.Fatalf("excepted oldest entry to be evicted")
+	}
+	if events := op.flushFn(true); len(events) != 2 {
+		t.Fatalf("excepted held entries to be flushed, got %d", len(events))
+	}
+
+	if _, err := newDedup(Params{"window": "0s"}); nil == err {
+		t.Fatalf("excepted error of invalid window")
+	}
+}
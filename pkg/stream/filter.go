@@ -0,0 +1,114 @@
+package stream
+
+import (
+	"github.com/jojohappy/luxun/pkg/expr"
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+// FilterConfig are the params of the filter operator, e.g.
+//
+//	operators:
+//	- name: filter
+//	  params:
+//	    expr: type == "Warning" && reason not in ["Pulled", "Created"]
+//	    include:
+//	      namespaces: [prod, staging]
+//	    exclude:
+//	      kinds: [Node]
+//	      labels:
+//	        app: canary
+//
+// An event passes if it matches every non-empty list of include, none of
+// exclude and the expression. Without params every event passes.
+type FilterConfig struct {
+	Expr    string      `yaml:"expr"`
+	Include FilterRules `yaml:"include"`
+	Exclude FilterRules `yaml:"exclude"`
+}
+
+type FilterRules struct {
+	Namespaces []string          `yaml:"namespaces"`
+	Kinds      []string          `yaml:"kinds"`
+	Reasons    []string          `yaml:"reasons"`
+	Labels     map[string]string `yaml:"labels"`
+}
+
+type eventFilter struct {
+	expr    *expr.Expr
+	include FilterRules
+	exclude FilterRules
+}
+
+func newFilter(params Params) (*Operator, error) {
+	config := FilterConfig{}
+	if err := params.Decode(&config); nil != err {
+		return nil, err
+	}
+	f := &eventFilter{
+		include: config.Include,
+		exclude: config.Exclude,
+	}
+	if config.Expr != "" {
+		e, err := expr.Compile(config.Expr)
+		if nil != err {
+			return nil, err
+		}
+		f.expr = e
+	}
+	return NewOperator(f.filter), nil
+}
+
+func (f *eventFilter) filter(en *model.Event) (*model.Event, error) {
+	if !f.match(en) {
+		return nil, nil
+	}
+	return en, nil
+}
+
+func (f *eventFilter) match(en *model.Event) bool {
+	in := f.include
+	if len(in.Namespaces) > 0 && !contains(in.Namespaces, en.Namespace) {
+		return false
+	}
+	if len(in.Kinds) > 0 && !contains(in.Kinds, en.Kind) {
+		return false
+	}
+	if len(in.Reasons) > 0 && !contains(in.Reasons, en.Reason) {
+		return false
+	}
+	for k, v := range in.Labels {
+		if !hasLabel(en, k, v) {
+			return false
+		}
+	}
+
+	ex := f.exclude
+	if contains(ex.Namespaces, en.Namespace) || contains(ex.Kinds, en.Kind) || contains(ex.Reasons, en.Reason) {
+		return false
+	}
+	for k, v := range ex.Labels {
+		if hasLabel(en, k, v) {
+			return false
+		}
+	}
+
+	return nil == f.expr || f.expr.Match(en)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLabel(en *model.Event, key, value string) bool {
+	for _, kv := range en.Labels {
+		if kv.Key == key && kv.Value == value {
+			return true
+		}
+	}
+	return false
+}
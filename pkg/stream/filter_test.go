@@ -0,0 +1,65 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestFilter(t *testing.T) {
+	config, err := ParsePipelineConfig([]byte(`
+operators:
+- name: filter
+  params:
+    expr: type == "Warning" && reason not in ["Pulled", "Created"]
+    include:
+      namespaces: [prod, staging]
+    exclude:
+      kinds: [Node]
+      labels:
+        app: canary
+`))
+	if nil != err {
+		t.Fatal(err)
+	}
+	ops, err := buildOperators(config)
+	if nil != err {
+		t.Fatal(err)
+	}
+	fn := ops[0].fn
+
+	cases := []struct {
+		ev       *model.Event
+		excepted bool
+	}{
+		{&model.Event{Namespace: "prod", Kind: "Pod", Type: "Warning", Reason: "BackOff"}, true},
+		{&model.Event{Namespace: "prod", Kind: "Pod", Type: "Normal", Reason: "BackOff"}, false},
+		{&model.Event{Namespace: "prod", Kind: "Pod", Type: "Warning", Reason: "Pulled"}, false},
+		{&model.Event{Namespace: "dev", Kind: "Pod", Type: "Warning", Reason: "BackOff"}, false},
+		{&model.Event{Namespace: "prod", Kind: "Node", Type: "Warning", Reason: "BackOff"}, false},
+		{&model.Event{Namespace: "prod", Kind: "Pod", Type: "Warning", Reason: "BackOff",
+			Labels: map[int]model.KVObject{0: {Key: "app", Value: "canary"}}}, false},
+	}
+	for i, c := range cases {
+		ev, err := fn(c.ev)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if (nil != ev) != c.excepted {
+			t.Fatalf("case %d: excepted %v", i, c.excepted)
+		}
+	}
+
+	// without params every event passes
+	op, err := newFilter(nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if ev, _ := op.fn(&model.Event{Type: "Normal"}); nil == ev {
+		t.Fatalf("excepted event to pass")
+	}
+
+	if _, err := newFilter(Params{"expr": "type =="}); nil == err {
+		t.Fatalf("excepted error of invalid expression")
+	}
+}
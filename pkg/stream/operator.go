@@ -2,6 +2,9 @@ package stream
 
 import (
 	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
 
 	"github.com/jojohappy/luxun/pkg/model"
 	"github.com/jojohappy/luxun/pkg/storage"
@@ -9,11 +12,40 @@ import (
 
 type opFunc func(en *model.Event) (*model.Event, error)
 
+// OperatorBuilder builds an operator from its params in the pipeline config.
+type OperatorBuilder func(params Params) (*Operator, error)
+
+var operatorBuilders = make(map[string]OperatorBuilder)
+
+// clock is the time source of operators built afterwards, replaced in tests.
+var clock = time.Now
+
+func RegisterOperator(name string, fn OperatorBuilder) {
+	operatorBuilders[name] = fn
+}
+
+func init() {
+	RegisterOperator("filter", newFilter)
+	RegisterOperator("dedup", newDedup)
+	RegisterOperator("ratelimit", newRateLimiter)
+	RegisterOperator("redact", newRedactor)
+	RegisterOperator("store", func(_ Params) (*Operator, error) {
+		return NewOperator(store), nil
+	})
+}
+
 type Operator struct {
-	input  <-chan *model.Event
-	output chan *model.Event
-	fn     opFunc
-	stopCh chan struct{}
+	input   <-chan *model.Event
+	output  chan *model.Event
+	fn      opFunc
+	stopCh  chan struct{}
+	stopped sync.Once
+
+	flushInterval time.Duration
+	flushFn       func(final bool) []*model.Event
+
+	shardKey func(en *model.Event) string
+	shards   []*Operator
 }
 
 func NewOperator(fn opFunc) *Operator {
@@ -24,31 +56,143 @@ func NewOperator(fn opFunc) *Operator {
 	}
 }
 
+// NewShardedOperator runs shards in parallel, events of the same shard key
+// always go to the same shard so their order is preserved. The key is the
+// one of the shards, see SetShardKey.
+func NewShardedOperator(shards ...*Operator) *Operator {
+	key := (*model.Event).ObjectKey
+	if len(shards) > 0 && nil != shards[0].shardKey {
+		key = shards[0].shardKey
+	}
+	return &Operator{
+		output:   make(chan *model.Event, len(shards)),
+		stopCh:   make(chan struct{}),
+		shardKey: key,
+		shards:   shards,
+	}
+}
+
+// SetFlush makes the operator call fn every interval and emit the events
+// it returns, for operators holding events back. fn runs on the same
+// goroutine as the op func, it is called once more with final set when
+// the input is closed and should then return everything held back.
+func (o *Operator) SetFlush(interval time.Duration, fn func(final bool) []*model.Event) {
+	o.flushInterval = interval
+	o.flushFn = fn
+}
+
+// SetShardKey makes events with the same key go to the same shard when the
+// operator runs with more than one worker, for operators keeping state per
+// key. Events are sharded by their involved object by default.
+func (o *Operator) SetShardKey(fn func(en *model.Event) string) {
+	o.shardKey = fn
+}
+
 func (o *Operator) Exec() {
+	if len(o.shards) > 0 {
+		o.execShards()
+		return
+	}
 	go func() {
 		defer func() {
 			close(o.output)
 		}()
+		var flushCh <-chan time.Time
+		if nil != o.flushFn {
+			ticker := time.NewTicker(o.flushInterval)
+			defer ticker.Stop()
+			flushCh = ticker.C
+		}
 		var e *model.Event
 		var err error
 		for {
 			select {
 			case en, opened := <-o.input:
 				if !opened {
-					continue
+					// the stream is shutting down, drain what is held back
+					if nil != o.flushFn {
+						for _, e := range o.flushFn(true) {
+							o.output <- e
+						}
+					}
+					return
 				}
 				e, err = o.fn(en)
 				if nil != err {
 					fmt.Printf("failed to process event: %s. skipped\n", err.Error())
 				}
+				// operators drop an event by returning nil
+				if nil == e {
+					continue
+				}
+				o.output <- e
+			case <-flushCh:
+				for _, e := range o.flushFn(false) {
+					o.output <- e
+				}
+			case <-o.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (o *Operator) execShards() {
+	inputs := make([]chan *model.Event, len(o.shards))
+	var wg sync.WaitGroup
+	for i, shard := range o.shards {
+		inputs[i] = make(chan *model.Event, 1)
+		shard.SetInput(inputs[i])
+		shard.Exec()
+		wg.Add(1)
+		go func(out <-chan *model.Event) {
+			defer wg.Done()
+			for e := range out {
 				o.output <- e
+			}
+		}(shard.GetOutput())
+	}
+	go func() {
+		wg.Wait()
+		close(o.output)
+	}()
+
+	go func() {
+		stop := func() {
+			for _, shard := range o.shards {
+				shard.Stop()
+			}
+		}
+		for {
+			select {
+			case en, opened := <-o.input:
+				if !opened {
+					// let the shards drain, the output is closed after them
+					for _, in := range inputs {
+						close(in)
+					}
+					return
+				}
+				select {
+				case inputs[shardOf(o.shardKey(en), len(inputs))] <- en:
+				case <-o.stopCh:
+					stop()
+					return
+				}
 			case <-o.stopCh:
+				stop()
 				return
 			}
 		}
 	}()
 }
 
+func shardOf(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
 func (o *Operator) SetInput(in <-chan *model.Event) {
 	o.input = in
 }
@@ -57,12 +201,19 @@ func (o *Operator) GetOutput() <-chan *model.Event {
 	return o.output
 }
 
-func (o *Operator) Stop() {
-	close(o.stopCh)
+// pending returns the number of events queued in the operator.
+func (o *Operator) pending() int {
+	n := len(o.output)
+	for _, shard := range o.shards {
+		n += len(shard.input) + shard.pending()
+	}
+	return n
 }
 
-func filter(en *model.Event) (*model.Event, error) {
-	return en, nil
+func (o *Operator) Stop() {
+	o.stopped.Do(func() {
+		close(o.stopCh)
+	})
 }
 
 func store(en *model.Event) (*model.Event, error) {
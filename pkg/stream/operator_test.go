@@ -0,0 +1,47 @@
+package stream
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestShardedOperator(t *testing.T) {
+	shards := make([]*Operator, 4)
+	for i := range shards {
+		shards[i] = NewOperator(func(en *model.Event) (*model.Event, error) {
+			time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+			return en, nil
+		})
+	}
+	op := NewShardedOperator(shards...)
+	input := make(chan *model.Event)
+	op.SetInput(input)
+	op.Exec()
+
+	objects, n := 8, 50
+	go func() {
+		for seq := 0; seq < n; seq++ {
+			for i := 0; i < objects; i++ {
+				input <- &model.Event{Kind: "Pod", ObjectName: fmt.Sprintf("pod-%d", i), Count: int32(seq)}
+			}
+		}
+	}()
+
+	last := make(map[string]int32)
+	for received := 0; received < objects*n; received++ {
+		select {
+		case ev := <-op.GetOutput():
+			if seq, ok := last[ev.ObjectName]; ok && ev.Count != seq+1 {
+				t.Fatalf("excepted event %d of %s, got %d", seq+1, ev.ObjectName, ev.Count)
+			}
+			last[ev.ObjectName] = ev.Count
+		case <-time.After(5 * time.Second):
+			t.Fatalf("excepted %d events, got %d", objects*n, received)
+		}
+	}
+	op.Stop()
+}
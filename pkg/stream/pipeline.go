@@ -0,0 +1,104 @@
+package stream
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var pipelineConfig = flag.String("pipeline-config", "", "path to the YAML config of stream operators, defaults to filter and store")
+
+// Params are the parameters of an operator in the pipeline config.
+type Params map[string]interface{}
+
+// Decode decodes the params into v, unknown params are rejected.
+func (p Params) Decode(v interface{}) error {
+	content, err := yaml.Marshal(p)
+	if nil != err {
+		return err
+	}
+	return yaml.UnmarshalStrict(content, v)
+}
+
+// OperatorConfig is an operator of the pipeline. With more than one
+// worker, the operator is built once per worker and events are sharded
+// across them by the key the operator keeps its state by, e.g. the
+// namespace for ratelimit keyed by namespace, or the involved object.
+// Limits on the size of that state, like maxEntries of dedup, apply per
+// worker.
+type OperatorConfig struct {
+	Name    string `yaml:"name"`
+	Workers int    `yaml:"workers"`
+	Params  Params `yaml:"params"`
+}
+
+// PipelineConfig lists the operators of the stream in order, e.g.
+//
+//	operators:
+//	- name: filter
+//	  params:
+//	    ...
+//	- name: dedup
+//	  workers: 4
+//	- name: store
+type PipelineConfig struct {
+	Operators []OperatorConfig `yaml:"operators"`
+}
+
+func defaultPipelineConfig() *PipelineConfig {
+	return &PipelineConfig{
+		Operators: []OperatorConfig{
+			{Name: "filter"},
+			{Name: "store"},
+		},
+	}
+}
+
+func LoadPipelineConfig(path string) (*PipelineConfig, error) {
+	if path == "" {
+		return defaultPipelineConfig(), nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, fmt.Errorf("failed to read pipeline config: %v", err)
+	}
+	return ParsePipelineConfig(content)
+}
+
+func ParsePipelineConfig(content []byte) (*PipelineConfig, error) {
+	config := &PipelineConfig{}
+	if err := yaml.UnmarshalStrict(content, config); nil != err {
+		return nil, fmt.Errorf("failed to parse pipeline config: %v", err)
+	}
+	return config, nil
+}
+
+// buildOperators builds the operators of config from the registry in order.
+func buildOperators(config *PipelineConfig) ([]*Operator, error) {
+	ops := make([]*Operator, 0, len(config.Operators))
+	for i, oc := range config.Operators {
+		builder, ok := operatorBuilders[oc.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown operator %q at position %d", oc.Name, i)
+		}
+		if oc.Workers < 0 {
+			return nil, fmt.Errorf("workers of operator %s at position %d must not be negative", oc.Name, i)
+		}
+		shards := make([]*Operator, 0, oc.Workers)
+		for len(shards) == 0 || len(shards) < oc.Workers {
+			op, err := builder(oc.Params)
+			if nil != err {
+				return nil, fmt.Errorf("failed to build operator %s at position %d: %v", oc.Name, i, err)
+			}
+			shards = append(shards, op)
+		}
+		if len(shards) == 1 {
+			ops = append(ops, shards[0])
+			continue
+		}
+		ops = append(ops, NewShardedOperator(shards...))
+	}
+	return ops, nil
+}
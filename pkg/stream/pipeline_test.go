@@ -0,0 +1,90 @@
+package stream
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestBuildOperators(t *testing.T) {
+	config, err := ParsePipelineConfig([]byte(`
+operators:
+- name: filter
+- name: store
+`))
+	if nil != err {
+		t.Fatal(err)
+	}
+	ops, err := buildOperators(config)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 {
+		t.Fatalf("excepted 2 operators, got %d", len(ops))
+	}
+
+	config, err = ParsePipelineConfig([]byte(`
+operators:
+- name: dedup
+  workers: 4
+`))
+	if nil != err {
+		t.Fatal(err)
+	}
+	ops, err = buildOperators(config)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 || len(ops[0].shards) != 4 {
+		t.Fatalf("excepted 1 operator of 4 shards")
+	}
+
+	config, err = ParsePipelineConfig([]byte(`
+operators:
+- name: unknown
+`))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := buildOperators(config); nil == err {
+		t.Fatalf("excepted error of unknown operator")
+	}
+}
+
+func TestShardedRateLimit(t *testing.T) {
+	config, err := ParsePipelineConfig([]byte(`
+operators:
+- name: ratelimit
+  workers: 4
+  params:
+    key: namespace
+    rate: 0.001
+    burst: 2
+`))
+	if nil != err {
+		t.Fatal(err)
+	}
+	ops, err := buildOperators(config)
+	if nil != err {
+		t.Fatal(err)
+	}
+	op := ops[0]
+	input := make(chan *model.Event, 16)
+	for i := 0; i < 16; i++ {
+		input <- &model.Event{Namespace: "default", Kind: "Pod", ObjectName: fmt.Sprintf("pod-%d", i), Type: "Warning"}
+	}
+	close(input)
+	op.SetInput(input)
+	op.Exec()
+
+	passed := 0
+	for ev := range op.GetOutput() {
+		if ev.Reason != "EventsSuppressed" {
+			passed++
+		}
+	}
+	if passed != 2 {
+		t.Fatalf("excepted burst of 2 events across workers, got %d", passed)
+	}
+}
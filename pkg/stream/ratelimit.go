@@ -0,0 +1,253 @@
+package stream
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+const (
+	RateLimitKeyNamespace = "namespace"
+	RateLimitKeyReason    = "reason"
+	RateLimitKeyObject    = "object"
+
+	SuppressedByRateLimit = "rate_limit"
+	SuppressedBySampling  = "sampling"
+
+	defaultSummaryInterval = time.Minute
+
+	// maxSuppressedKeys bounds the namespaces and reasons counted by
+	// SuppressedStats, the suppressions of further ones are counted under
+	// SuppressedOtherKey.
+	maxSuppressedKeys  = 100
+	SuppressedOtherKey = "_other"
+)
+
+// RateLimitConfig are the params of the ratelimit operator, e.g.
+//
+//	operators:
+//	- name: ratelimit
+//	  params:
+//	    key: namespace
+//	    rate: 10
+//	    burst: 100
+//	    sampleNormal: 0.1
+//	    summaryInterval: 1m
+//
+// Every key, the namespace, reason or involved object of events, gets a
+// token bucket refilled with rate tokens per second up to burst. Events
+// without a token are suppressed, rate 0 disables the limit. Normal events
+// are kept with the probability of sampleNormal before the limit applies.
+// Every summary interval a record of the events suppressed per key is
+// emitted.
+type RateLimitConfig struct {
+	Key             string        `yaml:"key"`
+	Rate            float64       `yaml:"rate"`
+	Burst           int           `yaml:"burst"`
+	SampleNormal    float64       `yaml:"sampleNormal"`
+	SummaryInterval time.Duration `yaml:"summaryInterval"`
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type suppression struct {
+	ev    *model.Event
+	count int
+}
+
+type rateLimiter struct {
+	key          func(en *model.Event) string
+	keyType      string
+	rate         float64
+	burst        float64
+	sampleNormal float64
+
+	buckets    map[string]*bucket
+	suppressed map[string]*suppression
+	now        func() time.Time
+	random     func() float64
+}
+
+func newRateLimiter(params Params) (*Operator, error) {
+	config := RateLimitConfig{
+		Key:             RateLimitKeyNamespace,
+		SampleNormal:    1,
+		SummaryInterval: defaultSummaryInterval,
+	}
+	if err := params.Decode(&config); nil != err {
+		return nil, err
+	}
+	r := &rateLimiter{
+		keyType:      config.Key,
+		rate:         config.Rate,
+		burst:        float64(config.Burst),
+		sampleNormal: config.SampleNormal,
+		buckets:      make(map[string]*bucket),
+		suppressed:   make(map[string]*suppression),
+		now:          clock,
+		random:       rand.New(rand.NewSource(time.Now().UnixNano())).Float64,
+	}
+	switch config.Key {
+	case RateLimitKeyNamespace:
+		r.key = func(en *model.Event) string { return en.Namespace }
+	case RateLimitKeyReason:
+		r.key = func(en *model.Event) string { return en.Reason }
+	case RateLimitKeyObject:
+		r.key = func(en *model.Event) string { return en.ObjectKey() }
+	default:
+		return nil, fmt.Errorf("unknown rate limit key %q", config.Key)
+	}
+	if config.Rate < 0 {
+		return nil, fmt.Errorf("rate must not be negative")
+	}
+	if config.Rate > 0 && config.Burst <= 0 {
+		return nil, fmt.Errorf("burst must be positive")
+	}
+	if config.SampleNormal < 0 || config.SampleNormal > 1 {
+		return nil, fmt.Errorf("sampleNormal must be between 0 and 1")
+	}
+	if config.SummaryInterval <= 0 {
+		return nil, fmt.Errorf("summaryInterval must be positive")
+	}
+
+	op := NewOperator(r.limit)
+	op.SetFlush(config.SummaryInterval, r.summary)
+	op.SetShardKey(r.key)
+	return op, nil
+}
+
+func (r *rateLimiter) limit(en *model.Event) (*model.Event, error) {
+	key := r.key(en)
+	if en.Type == "Normal" && r.sampleNormal < 1 && r.random() >= r.sampleNormal {
+		r.suppress(key, en, SuppressedBySampling)
+		return nil, nil
+	}
+	if r.rate == 0 {
+		return en, nil
+	}
+
+	now := r.now()
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &bucket{tokens: r.burst, last: now}
+		r.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * r.rate
+	if b.tokens > r.burst {
+		b.tokens = r.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		r.suppress(key, en, SuppressedByRateLimit)
+		return nil, nil
+	}
+	b.tokens--
+	return en, nil
+}
+
+func (r *rateLimiter) suppress(key string, en *model.Event, cause string) {
+	s, ok := r.suppressed[key]
+	if !ok {
+		s = &suppression{}
+		r.suppressed[key] = s
+	}
+	s.ev = en
+	s.count++
+	addSuppressed(cause, r.keyType, key)
+}
+
+// summary emits a record of the events suppressed per key since the last
+// summary, and forgets the buckets which are full again.
+func (r *rateLimiter) summary(_ bool) []*model.Event {
+	now := r.now()
+	for key, b := range r.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*r.rate >= r.burst {
+			delete(r.buckets, key)
+		}
+	}
+
+	keys := make([]string, 0, len(r.suppressed))
+	for key := range r.suppressed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	events := make([]*model.Event, 0, len(keys))
+	for _, key := range keys {
+		s := r.suppressed[key]
+		ev := &model.Event{
+			Time:    now,
+			Reason:  "EventsSuppressed",
+			Message: fmt.Sprintf("%d events suppressed for %s %s", s.count, r.keyType, key),
+			Count:   int32(s.count),
+			Type:    "Warning",
+			Env:     s.ev.Env,
+		}
+		switch r.keyType {
+		case RateLimitKeyNamespace:
+			ev.Namespace = s.ev.Namespace
+		case RateLimitKeyObject:
+			ev.Namespace = s.ev.Namespace
+			ev.Kind = s.ev.Kind
+			ev.ObjectName = s.ev.ObjectName
+		}
+		events = append(events, ev)
+		delete(r.suppressed, key)
+	}
+	return events
+}
+
+// SuppressedKey identifies a counter of suppressed events. Key is empty
+// for involved objects, which are only reported by the summaries.
+type SuppressedKey struct {
+	Cause   string
+	KeyType string
+	Key     string
+}
+
+var (
+	suppressedLock   sync.Mutex
+	suppressedCounts = make(map[SuppressedKey]uint64)
+	suppressedKeys   = make(map[string]map[string]bool)
+)
+
+func addSuppressed(cause, keyType, key string) {
+	suppressedLock.Lock()
+	defer suppressedLock.Unlock()
+	if keyType == RateLimitKeyObject {
+		key = ""
+	} else {
+		keys, ok := suppressedKeys[keyType]
+		if !ok {
+			keys = make(map[string]bool)
+			suppressedKeys[keyType] = keys
+		}
+		if !keys[key] {
+			if len(keys) >= maxSuppressedKeys {
+				key = SuppressedOtherKey
+			} else {
+				keys[key] = true
+			}
+		}
+	}
+	suppressedCounts[SuppressedKey{Cause: cause, KeyType: keyType, Key: key}]++
+}
+
+// SuppressedStats returns the number of events suppressed by rate limiting
+// or sampling per cause and key. Only the first namespaces and reasons are
+// counted on their own, see maxSuppressedKeys.
+func SuppressedStats() map[SuppressedKey]uint64 {
+	suppressedLock.Lock()
+	defer suppressedLock.Unlock()
+	stats := make(map[SuppressedKey]uint64, len(suppressedCounts))
+	for key, count := range suppressedCounts {
+		stats[key] = count
+	}
+	return stats
+}
@@ -0,0 +1,90 @@
+package stream
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)
+	clock = func() time.Time { return now }
+	defer func() { clock = time.Now }()
+	op, err := newRateLimiter(Params{"key": "namespace", "rate": 1, "burst": 2, "sampleNormal": 0})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if nil == op.flushFn {
+		t.Fatalf("excepted rate limiter to emit summaries")
+	}
+
+	passed := 0
+	for i := 0; i < 5; i++ {
+		if ev, _ := op.fn(&model.Event{Namespace: "prod", Type: "Warning"}); nil != ev {
+			passed++
+		}
+	}
+	if passed != 2 {
+		t.Fatalf("excepted 2 events within the burst, got %d", passed)
+	}
+	if ev, _ := op.fn(&model.Event{Namespace: "dev", Type: "Warning"}); nil == ev {
+		t.Fatalf("excepted other namespace not to be limited")
+	}
+	if ev, _ := op.fn(&model.Event{Namespace: "dev", Type: "Normal"}); nil != ev {
+		t.Fatalf("excepted normal event to be sampled out")
+	}
+
+	now = now.Add(time.Second)
+	if ev, _ := op.fn(&model.Event{Namespace: "prod", Type: "Warning"}); nil == ev {
+		t.Fatalf("excepted bucket to be refilled")
+	}
+
+	events := op.flushFn(false)
+	if len(events) != 2 {
+		t.Fatalf("excepted 2 summaries, got %d", len(events))
+	}
+	if events[1].Namespace != "prod" || events[1].Count != 3 || events[1].Message != "3 events suppressed for namespace prod" {
+		t.Fatalf("excepted summary of prod, got %+v", events[1])
+	}
+	if len(op.flushFn(false)) != 0 {
+		t.Fatalf("excepted no summary without suppressed events")
+	}
+
+	stats := SuppressedStats()
+	if stats[SuppressedKey{Cause: SuppressedByRateLimit, KeyType: RateLimitKeyNamespace, Key: "prod"}] < 3 {
+		t.Fatalf("excepted suppressed events of prod to be counted, got %v", stats)
+	}
+	if stats[SuppressedKey{Cause: SuppressedBySampling, KeyType: RateLimitKeyNamespace, Key: "dev"}] < 1 {
+		t.Fatalf("excepted sampled events of dev to be counted, got %v", stats)
+	}
+
+	if _, err := newRateLimiter(Params{"key": "node"}); nil == err {
+		t.Fatalf("excepted error of unknown key")
+	}
+}
+
+func TestSuppressedKeysBounded(t *testing.T) {
+	for i := 0; i < maxSuppressedKeys+10; i++ {
+		addSuppressed(SuppressedByRateLimit, RateLimitKeyReason, fmt.Sprintf("reason-%d", i))
+	}
+	addSuppressed(SuppressedByRateLimit, RateLimitKeyObject, "Pod/default/web")
+
+	stats := SuppressedStats()
+	reasons := 0
+	for key := range stats {
+		if key.KeyType == RateLimitKeyReason {
+			reasons++
+		}
+	}
+	if reasons > maxSuppressedKeys+1 {
+		t.Fatalf("excepted at most %d reasons counted, got %d", maxSuppressedKeys+1, reasons)
+	}
+	if stats[SuppressedKey{Cause: SuppressedByRateLimit, KeyType: RateLimitKeyReason, Key: SuppressedOtherKey}] < 10 {
+		t.Fatalf("excepted further reasons counted as %s, got %v", SuppressedOtherKey, stats)
+	}
+	if stats[SuppressedKey{Cause: SuppressedByRateLimit, KeyType: RateLimitKeyObject}] < 1 {
+		t.Fatalf("excepted objects counted without their key")
+	}
+}
@@ -0,0 +1,177 @@
+package stream
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sync"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+const (
+	defaultRedactMask = "***"
+
+	redactedAnnotation = "annotation"
+	redactedLabel      = "label"
+)
+
+// RedactConfig are the params of the redact operator, e.g.
+//
+//	operators:
+//	- name: redact
+//	  params:
+//	    rules:
+//	    - name: token
+//	      pattern: (?i)(token|password)=\S+
+//	      replacement: $1=***
+//	    denyAnnotations:
+//	    - kubectl.kubernetes.io/last-applied-configuration
+//	    denyLabels:
+//	    - secret.example.com/*
+//
+// The rules are applied in order to the message, the values of labels and
+// annotations and the messages of the pod condition, node condition and
+// container status.
+// The values of labels and annotations whose key matches a deny-list, a
+// shell pattern, are replaced with mask as a whole.
+type RedactConfig struct {
+	Rules           []RedactRule `yaml:"rules"`
+	DenyAnnotations []string     `yaml:"denyAnnotations"`
+	DenyLabels      []string     `yaml:"denyLabels"`
+	Mask            string       `yaml:"mask"`
+}
+
+// RedactRule replaces the matches of pattern with replacement, which may
+// refer to submatches as in regexp.ReplaceAllString. Replacement defaults
+// to the mask.
+type RedactRule struct {
+	Name        string `yaml:"name"`
+	Pattern     string `yaml:"pattern"`
+	Replacement string `yaml:"replacement"`
+}
+
+type redactRule struct {
+	name        string
+	re          *regexp.Regexp
+	replacement string
+}
+
+type redactor struct {
+	rules           []redactRule
+	denyAnnotations []string
+	denyLabels      []string
+	mask            string
+}
+
+func newRedactor(params Params) (*Operator, error) {
+	config := RedactConfig{
+		Mask: defaultRedactMask,
+	}
+	if err := params.Decode(&config); nil != err {
+		return nil, err
+	}
+	r := &redactor{
+		rules:           make([]redactRule, 0, len(config.Rules)),
+		denyAnnotations: config.DenyAnnotations,
+		denyLabels:      config.DenyLabels,
+		mask:            config.Mask,
+	}
+	for i, rule := range config.Rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if nil != err {
+			return nil, fmt.Errorf("invalid pattern of redact rule %d: %v", i, err)
+		}
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i)
+		}
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = config.Mask
+		}
+		r.rules = append(r.rules, redactRule{name: name, re: re, replacement: replacement})
+	}
+	for _, pattern := range append(append([]string{}, config.DenyAnnotations...), config.DenyLabels...) {
+		if _, err := path.Match(pattern, ""); nil != err {
+			return nil, fmt.Errorf("invalid deny pattern %q: %v", pattern, err)
+		}
+	}
+	return NewOperator(r.redact), nil
+}
+
+func (r *redactor) redact(en *model.Event) (*model.Event, error) {
+	en.Message = r.apply(en.Message)
+	en.PodCondition.Message = r.apply(en.PodCondition.Message)
+	if nil != en.NodeCondition {
+		en.NodeCondition.Message = r.apply(en.NodeCondition.Message)
+	}
+	for i, cs := range en.ContainerStatus {
+		cs.Message = r.apply(cs.Message)
+		en.ContainerStatus[i] = cs
+	}
+	r.redactKVs(en.Labels, r.denyLabels, redactedLabel)
+	r.redactKVs(en.Annotations, r.denyAnnotations, redactedAnnotation)
+	return en, nil
+}
+
+func (r *redactor) redactKVs(kvs map[int]model.KVObject, deny []string, kind string) {
+	for i, kv := range kvs {
+		if denied(kv.Key, deny) {
+			if kv.Value != r.mask {
+				kv.Value = r.mask
+				addRedactions(kind, 1)
+			}
+		} else {
+			kv.Value = r.apply(kv.Value)
+		}
+		kvs[i] = kv
+	}
+}
+
+func (r *redactor) apply(s string) string {
+	if s == "" {
+		return s
+	}
+	for _, rule := range r.rules {
+		matches := rule.re.FindAllStringIndex(s, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		s = rule.re.ReplaceAllString(s, rule.replacement)
+		addRedactions(rule.name, len(matches))
+	}
+	return s
+}
+
+func denied(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	redactionsLock   sync.Mutex
+	redactionsCounts = make(map[string]uint64)
+)
+
+func addRedactions(rule string, n int) {
+	redactionsLock.Lock()
+	redactionsCounts[rule] += uint64(n)
+	redactionsLock.Unlock()
+}
+
+// RedactionStats returns the number of redactions applied per rule, values
+// of denied annotations and labels are counted as annotation and label.
+func RedactionStats() map[string]uint64 {
+	redactionsLock.Lock()
+	defer redactionsLock.Unlock()
+	stats := make(map[string]uint64, len(redactionsCounts))
+	for rule, count := range redactionsCounts {
+		stats[rule] = count
+	}
+	return stats
+}
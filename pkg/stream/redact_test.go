@@ -0,0 +1,65 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+func TestRedact(t *testing.T) {
+	op, err := newRedactor(Params{
+		"rules": []interface{}{
+			map[string]interface{}{"name": "token", "pattern": `(?i)(token|password)=\S+`, "replacement": "$1=***"},
+			map[string]interface{}{"pattern": `postgres://\S+`},
+		},
+		"denyAnnotations": []interface{}{"kubectl.kubernetes.io/*"},
+		"denyLabels":      []interface{}{"secret"},
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	ev := &model.Event{
+		Message: "failed to connect to postgres://admin:pw@db:5432 with TOKEN=abc password=def",
+		Labels: map[int]model.KVObject{
+			0: {Key: "app", Value: "web"},
+			1: {Key: "secret", Value: "s3cr3t"},
+		},
+		Annotations: map[int]model.KVObject{
+			0: {Key: "kubectl.kubernetes.io/last-applied-configuration", Value: `{"env":"x"}`},
+			1: {Key: "note", Value: "token=xyz"},
+		},
+		ContainerStatus: map[int]model.ContainerStatus{
+			0: {Message: "password=hunter2"},
+		},
+		NodeCondition: &model.NodeCondition{Message: "kubelet token=abc"},
+	}
+	before := RedactionStats()
+	ev, _ = op.fn(ev)
+
+	if ev.Message != "failed to connect to *** with TOKEN=*** password=***" {
+		t.Fatalf("excepted message to be redacted, got %s", ev.Message)
+	}
+	if ev.Labels[0].Value != "web" || ev.Labels[1].Value != "***" {
+		t.Fatalf("excepted denied label to be masked, got %v", ev.Labels)
+	}
+	if ev.Annotations[0].Value != "***" || ev.Annotations[1].Value != "token=***" {
+		t.Fatalf("excepted annotations to be redacted, got %v", ev.Annotations)
+	}
+	if ev.ContainerStatus[0].Message != "password=***" {
+		t.Fatalf("excepted container status message to be redacted, got %s", ev.ContainerStatus[0].Message)
+	}
+	if ev.NodeCondition.Message != "kubelet token=***" {
+		t.Fatalf("excepted node condition message to be redacted, got %s", ev.NodeCondition.Message)
+	}
+
+	after := RedactionStats()
+	if after["token"]-before["token"] != 5 || after["rule-1"]-before["rule-1"] != 1 ||
+		after["label"]-before["label"] != 1 || after["annotation"]-before["annotation"] != 1 {
+		t.Fatalf("excepted redactions to be counted, got %v", after)
+	}
+
+	if _, err := newRedactor(Params{"rules": []interface{}{map[string]interface{}{"pattern": "("}}}); nil == err {
+		t.Fatalf("excepted error of invalid pattern")
+	}
+}
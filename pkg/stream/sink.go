@@ -1,19 +1,105 @@
 package stream
 
 import (
+	"flag"
 	"fmt"
+	"sync"
+	"time"
 
-	"github.com/jojohappy/luxun/pkg/handler/elasticsearch"
+	"github.com/jojohappy/luxun/pkg/buffer"
+	"github.com/jojohappy/luxun/pkg/deadletter"
+	"github.com/jojohappy/luxun/pkg/handler"
 	"github.com/jojohappy/luxun/pkg/model"
 )
 
+var sinkQueueSize = flag.Int("sink-queue-size", 1000, "size of the queue in front of each sink")
+
+// Sink fans events out to every configured handler.Sink. Each handler
+// gets its own queue and goroutine, so a slow or failing backend does
+// not hold up the others. With a buffer, events are appended to it and
+// every handler reads them back from its own checkpoint.
 type Sink struct {
-	input  <-chan *model.Event
-	stopCh chan struct{}
+	input   <-chan *model.Event
+	sinks   []handler.Sink
+	buffer  *buffer.Buffer
+	readers []*buffer.Reader
+	queues  []chan entry
+	stopCh  chan struct{}
+	stopped sync.Once
+}
+
+type entry struct {
+	ev  *model.Event
+	ack func()
+}
+
+// tracker commits the buffer position of events in the order they are
+// read, once they are acknowledged by the handler. At most limit events
+// are waiting for their acknowledgement, the reader blocks on the rest.
+type tracker struct {
+	r       *buffer.Reader
+	slots   chan struct{}
+	lock    sync.Mutex
+	pending []*delivery
 }
 
-func NewSink() *Sink {
+type delivery struct {
+	pos   buffer.Position
+	acked bool
+}
+
+func newTracker(r *buffer.Reader, limit int) *tracker {
+	return &tracker{
+		r:     r,
+		slots: make(chan struct{}, limit),
+	}
+}
+
+// add registers pos as read, it blocks while too many events are not
+// acknowledged yet and returns nil if stopCh is closed meanwhile.
+func (t *tracker) add(pos buffer.Position, stopCh <-chan struct{}) *delivery {
+	select {
+	case t.slots <- struct{}{}:
+	case <-stopCh:
+		return nil
+	}
+	d := &delivery{pos: pos}
+	t.lock.Lock()
+	t.pending = append(t.pending, d)
+	t.lock.Unlock()
+	return d
+}
+
+// ack commits the positions of the acknowledged events which are not
+// preceded by an unacknowledged one.
+func (t *tracker) ack(d *delivery) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	d.acked = true
+	n := 0
+	for n < len(t.pending) && t.pending[n].acked {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	if err := t.r.Commit(t.pending[n-1].pos); nil != err {
+		fmt.Printf("failed to save buffer checkpoint: %s\n", err.Error())
+	}
+	t.pending = t.pending[n:]
+	for i := 0; i < n; i++ {
+		<-t.slots
+	}
+}
+
+func NewSink(sinks ...handler.Sink) *Sink {
+	queues := make([]chan entry, len(sinks))
+	for i := range queues {
+		queues[i] = make(chan entry, *sinkQueueSize)
+	}
 	return &Sink{
+		sinks:  sinks,
+		queues: queues,
 		stopCh: make(chan struct{}),
 	}
 }
@@ -22,24 +108,84 @@ func (s *Sink) SetInput(in <-chan *model.Event) {
 	s.input = in
 }
 
+func (s *Sink) SetBuffer(b *buffer.Buffer) error {
+	readers := make([]*buffer.Reader, len(s.sinks))
+	for i, hs := range s.sinks {
+		r, err := b.Reader(hs.Name())
+		if nil != err {
+			return err
+		}
+		readers[i] = r
+	}
+	s.buffer = b
+	s.readers = readers
+	return nil
+}
+
 func (s *Sink) Exec() <-chan error {
 	result := make(chan error)
+	var wg sync.WaitGroup
+	queues := s.queues
+	for i, hs := range s.sinks {
+		if nil != s.buffer {
+			go s.readBuffer(s.readers[i], queues[i], result)
+		}
+		wg.Add(1)
+		go func(hs handler.Sink, q <-chan entry) {
+			defer wg.Done()
+			s.runSink(hs, q, result)
+			hs.Stop()
+		}(hs, queues[i])
+	}
+
 	go func() {
-		var err error
+		// once the input is closed or the sink is stopped, every handler
+		// writes what is left in its queue before it is stopped. Events left
+		// in the buffer are read again on the next start.
+		defer func() {
+			if nil == s.buffer {
+				for _, q := range queues {
+					close(q)
+				}
+			} else {
+				s.Stop()
+			}
+			wg.Wait()
+			if nil != s.buffer {
+				for _, r := range s.readers {
+					if err := r.Sync(); nil != err {
+						fmt.Printf("failed to save buffer checkpoint: %s\n", err.Error())
+					}
+				}
+				s.buffer.Close()
+			}
+			close(result)
+		}()
 		for {
 			select {
 			case ev, opened := <-s.input:
 				if !opened {
+					return
+				}
+				if nil != s.buffer {
+					if err := s.buffer.Append(ev); nil != err {
+						for _, hs := range s.sinks {
+							deadletter.Add(hs.Name(), ev, err)
+						}
+						result <- err
+					}
 					continue
 				}
-				// send to es
-				err = sendToEs(ev)
-				if nil != err {
-					fmt.Printf("failed to push event to elasticsearch: %s\n", err.Error())
-					result <- err
+				for i, q := range queues {
+					select {
+					case q <- entry{ev: ev}:
+					default:
+						err := fmt.Errorf("sink queue is full")
+						deadletter.Add(s.sinks[i].Name(), ev, err)
+						result <- fmt.Errorf("%s: %v", s.sinks[i].Name(), err)
+					}
 				}
 			case <-s.stopCh:
-				close(result)
 				return
 			}
 		}
@@ -47,10 +193,121 @@ func (s *Sink) Exec() <-chan error {
 	return result
 }
 
+// readBuffer feeds q with events read from r until the sink is stopped.
+// Events left in the buffer or not acknowledged by the handler are read
+// again on the next start.
+func (s *Sink) readBuffer(r *buffer.Reader, q chan<- entry, result chan<- error) {
+	defer close(q)
+	t := newTracker(r, *sinkQueueSize)
+	for {
+		ev, pos, err := r.Next(s.stopCh)
+		if nil != err {
+			result <- fmt.Errorf("failed to read buffer: %v", err)
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-s.stopCh:
+				return
+			}
+		}
+		if nil == ev {
+			return
+		}
+		d := t.add(pos, s.stopCh)
+		if nil == d {
+			return
+		}
+		select {
+		case q <- entry{ev: ev, ack: func() { t.ack(d) }}:
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// runSink writes events to hs until q is closed. Events failed to be
+// delivered go to the dead letter queue, which is replayed periodically
+// aside, so waiting for replayed events never holds up new ones.
+// Buffered events are acknowledged once hs reports their delivery, except
+// the ones failed because hs is stopping, they stay in the buffer.
+func (s *Sink) runSink(hs handler.Sink, q <-chan entry, result chan<- error) {
+	var replayCh <-chan time.Time
+	if deadletter.Enabled() {
+		ticker := time.NewTicker(deadletter.ReplayInterval())
+		defer ticker.Stop()
+		replayCh = ticker.C
+	}
+	var replayed chan struct{}
+	defer func() {
+		if nil != replayed {
+			<-replayed
+		}
+	}()
+	for {
+		select {
+		case e, opened := <-q:
+			if !opened {
+				return
+			}
+			ev, ack := e.ev, e.ack
+			handler.Deliver(hs, ev, func(err error) {
+				if nil != err {
+					if nil != ack && s.stopping() && !handler.IsPermanent(err) {
+						return
+					}
+					deadletter.Add(hs.Name(), ev, err)
+					result <- fmt.Errorf("%s: %v", hs.Name(), err)
+				}
+				if nil != ack {
+					ack()
+				}
+			})
+		case <-replayCh:
+			if nil != replayed {
+				select {
+				case <-replayed:
+				default:
+					// the last replay is still waiting for deliveries
+					continue
+				}
+			}
+			replayed = make(chan struct{})
+			go func(replayed chan struct{}) {
+				defer close(replayed)
+				n, err := deadletter.Replay(hs.Name(), func(ev *model.Event, done func(err error)) {
+					handler.Deliver(hs, ev, done)
+				})
+				if n > 0 {
+					fmt.Printf("replayed %d dead letters to %s\n", n, hs.Name())
+				}
+				if nil != err {
+					result <- fmt.Errorf("%s: failed to replay dead letters: %v", hs.Name(), err)
+				}
+			}(replayed)
+		}
+	}
+}
+
 func (s *Sink) Stop() {
-	close(s.stopCh)
+	s.stopped.Do(func() {
+		close(s.stopCh)
+	})
 }
 
-func sendToEs(ev *model.Event) error {
-	return elasticsearch.ElasticSource().Bulk(ev)
+func (s *Sink) stopping() bool {
+	select {
+	case <-s.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// pending returns the number of events waiting in the queues of handlers.
+func (s *Sink) pending() int {
+	n := 0
+	for _, q := range s.queues {
+		n += len(q)
+	}
+	return n
 }
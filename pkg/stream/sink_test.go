@@ -0,0 +1,109 @@
+package stream
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jojohappy/luxun/pkg/buffer"
+	"github.com/jojohappy/luxun/pkg/handler"
+	"github.com/jojohappy/luxun/pkg/model"
+)
+
+// stalledSink accepts events but never acknowledges them.
+type stalledSink struct {
+	lock   sync.Mutex
+	events []*model.Event
+}
+
+func (s *stalledSink) Name() string {
+	return "record"
+}
+
+func (s *stalledSink) Write(ev *model.Event) error {
+	return s.WriteAck(ev, nil)
+}
+
+func (s *stalledSink) WriteAck(ev *model.Event, done func(err error)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.events = append(s.events, ev)
+	return nil
+}
+
+func (s *stalledSink) Stop() {}
+
+func (s *stalledSink) count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.events)
+}
+
+func runBuffered(t *testing.T, dir string, hs handler.Sink, events ...*model.Event) (*Sink, <-chan error) {
+	b, err := buffer.Open(dir, 1<<20, 0, 0, false)
+	if nil != err {
+		t.Fatal(err)
+	}
+	sink := NewSink(hs)
+	if err := sink.SetBuffer(b); nil != err {
+		t.Fatal(err)
+	}
+	input := make(chan *model.Event, len(events))
+	for _, ev := range events {
+		input <- ev
+	}
+	sink.SetInput(input)
+	return sink, sink.Exec()
+}
+
+func TestSinkRedeliversUnacked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	events := make([]*model.Event, 3)
+	for i := range events {
+		events[i] = &model.Event{Kind: "Pod", ObjectName: fmt.Sprintf("pod-%d", i)}
+	}
+	stalled := &stalledSink{}
+	sink, result := runBuffered(t, dir, stalled, events...)
+	deadline := time.Now().Add(5 * time.Second)
+	for stalled.count() < len(events) {
+		if time.Now().After(deadline) {
+			t.Fatalf("excepted %d events written to the stalled sink, got %d", len(events), stalled.count())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sink.Stop()
+	for range result {
+	}
+
+	rs := &recordSink{}
+	sink, result = runBuffered(t, dir, rs)
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		rs.lock.Lock()
+		n := len(rs.events)
+		rs.lock.Unlock()
+		if n == len(events) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("excepted %d events redelivered, got %d", len(events), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sink.Stop()
+	for range result {
+	}
+	for i, ev := range rs.events {
+		if ev.ObjectName != events[i].ObjectName {
+			t.Fatalf("excepted %s redelivered, got %s", events[i].ObjectName, ev.ObjectName)
+		}
+	}
+}
@@ -1,77 +1,271 @@
 package stream
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/jojohappy/luxun/pkg/buffer"
+	"github.com/jojohappy/luxun/pkg/handler"
 	"github.com/jojohappy/luxun/pkg/model"
 )
 
+const (
+	OverflowBlock = "block"
+	OverflowDrop  = "drop"
+)
+
+var (
+	sinkNames      = flag.String("sinks", "elasticsearch", "comma separated list of sinks that events are delivered to")
+	inputSize      = flag.Int("stream-input-size", 1000, "size of the input queue of the stream")
+	overflowPolicy = flag.String("stream-overflow-policy", OverflowBlock, "what Process does when the input queue is full, block or drop")
+	blockTimeout   = flag.Duration("stream-block-timeout", 0, "max time Process blocks on a full input queue before dropping the event, 0 blocks until it is accepted")
+)
+
+var (
+	ErrDropped = errors.New("stream input queue is full, event dropped")
+	ErrStopped = errors.New("stream is stopped")
+)
+
 type Stream struct {
+	// accessed atomically, keep it 64-bit aligned
+	dropped uint64
+
 	input chan *model.Event
 	ops   []*Operator
 	sink  *Sink
+	done  chan struct{}
+
+	// closed when the shutdown begins, it wakes up senders blocked on a full
+	// input so they release the lock
+	closing     chan struct{}
+	closingOnce sync.Once
+
+	// held for reading while putting events into input, so input is not
+	// closed under a sender
+	lock   sync.RWMutex
+	closed bool
 }
 
 var defaultStream *Stream
 
 func NewStream() *Stream {
+	size := *inputSize
+	if size <= 0 {
+		size = 1
+	}
 	return &Stream{
-		input: make(chan *model.Event, 1),
-		ops:   make([]*Operator, 0),
+		input:   make(chan *model.Event, size),
+		ops:     make([]*Operator, 0),
+		done:    make(chan struct{}),
+		closing: make(chan struct{}),
 	}
 }
 
-func Init() {
+func Init() error {
+	if *overflowPolicy != OverflowBlock && *overflowPolicy != OverflowDrop {
+		return fmt.Errorf("unknown stream overflow policy %q", *overflowPolicy)
+	}
 	defaultStream = NewStream()
 
-	filterOp := NewOperator(filter)
-	filterOp.SetInput(defaultStream.input)
-	defaultStream.ops = append(defaultStream.ops, filterOp)
+	config, err := LoadPipelineConfig(*pipelineConfig)
+	if nil != err {
+		return err
+	}
+	ops, err := buildOperators(config)
+	if nil != err {
+		return err
+	}
+	buf, err := buffer.Init()
+	if nil != err {
+		return fmt.Errorf("failed to open buffer: %v", err)
+	}
+	sinks, err := newSinks(*sinkNames)
+	if nil != err {
+		return err
+	}
 
-	storeOp := NewOperator(store)
-	storeOp.SetInput(filterOp.GetOutput())
-	defaultStream.ops = append(defaultStream.ops, storeOp)
+	var output <-chan *model.Event = defaultStream.input
+	for _, op := range ops {
+		op.SetInput(output)
+		output = op.GetOutput()
+		defaultStream.ops = append(defaultStream.ops, op)
+	}
 
-	sink := NewSink()
-	sink.SetInput(storeOp.GetOutput())
+	sink := NewSink(sinks...)
+	sink.SetInput(output)
+	if nil != buf {
+		if err := sink.SetBuffer(buf); nil != err {
+			return fmt.Errorf("failed to open buffer: %v", err)
+		}
+	}
 	defaultStream.sink = sink
 
 	defaultStream.start()
+	return nil
 }
 
-func Process(ev ...*model.Event) {
-	go func() {
-		for _, e := range ev {
-			defaultStream.input <- e
+func newSinks(names string) ([]handler.Sink, error) {
+	sinks := make([]handler.Sink, 0)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
 		}
-	}()
+		s, err := handler.NewSink(name)
+		if nil != err {
+			for _, created := range sinks {
+				created.Stop()
+			}
+			return nil, fmt.Errorf("failed to create sink %s: %v", name, err)
+		}
+		sinks = append(sinks, s)
+	}
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("no sink was configured")
+	}
+	return sinks, nil
+}
+
+// Process puts events into the stream in order. When the input queue is
+// full it either blocks, up to the block timeout, or drops the events,
+// depending on the overflow policy.
+func Process(ev ...*model.Event) error {
+	s := defaultStream
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return ErrStopped
+	}
+
+	if *overflowPolicy == OverflowDrop {
+		for i, e := range ev {
+			select {
+			case s.input <- e:
+			default:
+				s.drop(len(ev) - i)
+				return ErrDropped
+			}
+		}
+		return nil
+	}
+
+	ctx := context.Background()
+	if *blockTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *blockTimeout)
+		defer cancel()
+	}
+	if err := s.process(ctx, ev...); nil != err {
+		if err == ErrStopped {
+			return err
+		}
+		return ErrDropped
+	}
+	return nil
+}
+
+func (s *Stream) process(ctx context.Context, ev ...*model.Event) error {
+	for i, e := range ev {
+		select {
+		case s.input <- e:
+		case <-ctx.Done():
+			s.drop(len(ev) - i)
+			return ctx.Err()
+		case <-s.closing:
+			s.drop(len(ev) - i)
+			return ErrStopped
+		}
+	}
+	return nil
+}
+
+// Stats returns the depth and capacity of the input queue and the number
+// of events dropped so far.
+func Stats() (depth, capacity int, dropped uint64) {
+	if nil == defaultStream {
+		return 0, 0, 0
+	}
+	return len(defaultStream.input), cap(defaultStream.input), atomic.LoadUint64(&defaultStream.dropped)
 }
 
 func Stop() {
 	defaultStream.stop()
 }
 
+// Shutdown stops accepting events and waits until the operators are
+// drained and the sinks are flushed and stopped, or ctx is done. In the
+// latter case the stream is stopped right away and the error tells how
+// many events were left in it. Senders blocked on a full input give up.
+func Shutdown(ctx context.Context) error {
+	if nil == defaultStream {
+		return nil
+	}
+	return defaultStream.shutdown(ctx)
+}
+
 func (s *Stream) start() {
 	go func() {
 		for _, op := range s.ops {
 			op.Exec()
 		}
 		r := s.sink.Exec()
-		for {
-			select {
-			case err := <-r:
-				if nil != err {
-					fmt.Printf("failed to sink %s\n", err.Error())
-				}
-			}
+		for err := range r {
+			fmt.Printf("failed to sink %s\n", err.Error())
 		}
+		close(s.done)
 	}()
 }
 
+func (s *Stream) shutdown(ctx context.Context) error {
+	s.closingOnce.Do(func() {
+		close(s.closing)
+	})
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.input)
+	s.lock.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+	}
+	n := s.pending()
+	s.stop()
+	return fmt.Errorf("%d events were not flushed before the deadline: %v", n, ctx.Err())
+}
+
+// pending returns the number of events queued in the stream.
+func (s *Stream) pending() int {
+	n := len(s.input)
+	for _, op := range s.ops {
+		n += op.pending()
+	}
+	if nil != s.sink {
+		n += s.sink.pending()
+	}
+	return n
+}
+
+func (s *Stream) drop(n int) {
+	atomic.AddUint64(&s.dropped, uint64(n))
+}
+
 func (s *Stream) stop() {
 	for _, op := range s.ops {
 		op.Stop()
 	}
 
-	s.sink.Stop()
+	if nil != s.sink {
+		s.sink.Stop()
+	}
 }
@@ -1,42 +1,181 @@
 package stream
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/jojohappy/luxun/pkg/handler"
 	"github.com/jojohappy/luxun/pkg/model"
 )
 
-var count int
-
 func testOp(en *model.Event) (*model.Event, error) {
-	count++
 	return en, nil
 }
 
 func TestStream(t *testing.T) {
-	count = 0
+	rs := &recordSink{}
+	handler.RegisterSink("test", func() (handler.Sink, error) {
+		return rs, nil
+	})
+	names := *sinkNames
+	*sinkNames = "test"
+	defer func() { *sinkNames = names }()
 
-	Init()
-	Stop()
-	defaultStream = NewStream()
+	if err := Init(); nil != err {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := Process(&model.Event{Kind: "Pod", ObjectName: fmt.Sprintf("pod-%d", i)}); nil != err {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Shutdown(ctx); nil != err {
+		t.Fatal(err)
+	}
+
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	if len(rs.events) != 3 || !rs.stopped {
+		t.Fatalf("excepted 3 events delivered to the stopped sink, got %d, %v", len(rs.events), rs.stopped)
+	}
+	for i, ev := range rs.events {
+		if ev.ObjectName != fmt.Sprintf("pod-%d", i) {
+			t.Fatalf("excepted events delivered in order, got %s at %d", ev.ObjectName, i)
+		}
+	}
+}
+
+type recordSink struct {
+	lock    sync.Mutex
+	events  []*model.Event
+	stopped bool
+}
+
+func (r *recordSink) Name() string {
+	return "record"
+}
+
+func (r *recordSink) Write(ev *model.Event) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, ev)
+	return nil
+}
+
+func (r *recordSink) Stop() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stopped = true
+}
 
-	op := NewOperator(testOp)
+func TestShutdown(t *testing.T) {
+	defaultStream = NewStream()
+	op, err := newDedup(Params{"window": "1h"})
+	if nil != err {
+		t.Fatal(err)
+	}
 	op.SetInput(defaultStream.input)
 	defaultStream.ops = append(defaultStream.ops, op)
-	sink := NewSink()
+	rs := &recordSink{}
+	sink := NewSink(rs)
 	sink.SetInput(op.GetOutput())
 	defaultStream.sink = sink
 	defaultStream.start()
 
-	ev := &model.Event{}
+	for i := 0; i < 3; i++ {
+		if err := Process(&model.Event{Kind: "Pod", ObjectName: fmt.Sprintf("pod-%d", i)}); nil != err {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Shutdown(ctx); nil != err {
+		t.Fatal(err)
+	}
 
-	Process(ev)
-	time.Sleep(time.Second)
+	rs.lock.Lock()
+	if len(rs.events) != 3 || !rs.stopped {
+		t.Fatalf("excepted 3 events flushed to the stopped sink, got %d, %v", len(rs.events), rs.stopped)
+	}
+	rs.lock.Unlock()
+	if err := Process(&model.Event{}); err != ErrStopped {
+		t.Fatalf("excepted ErrStopped, got %v", err)
+	}
+}
+
+func TestShutdownBlockedSender(t *testing.T) {
+	defaultStream = &Stream{
+		input:   make(chan *model.Event, 1),
+		ops:     []*Operator{NewOperator(testOp)},
+		done:    make(chan struct{}),
+		closing: make(chan struct{}),
+	}
+	defaultStream.input <- &model.Event{}
+	blocked := make(chan error)
+	go func() {
+		blocked <- Process(&model.Event{})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(ctx); nil == err {
+		t.Fatalf("excepted the event left in the stream reported")
+	}
+	if err := <-blocked; err != ErrStopped {
+		t.Fatalf("excepted ErrStopped, got %v", err)
+	}
+	Stop()
 	Stop()
+}
+
+func TestProcessOverflow(t *testing.T) {
+	policy, timeout := *overflowPolicy, *blockTimeout
+	defer func() {
+		*overflowPolicy, *blockTimeout = policy, timeout
+	}()
+
+	cases := []struct {
+		policy  string
+		timeout time.Duration
+		// drain frees the input after the delay, 0 never frees it
+		drain    time.Duration
+		excepted error
+		dropped  uint64
+	}{
+		{policy: OverflowDrop, excepted: ErrDropped, dropped: 2},
+		{policy: OverflowBlock, timeout: 20 * time.Millisecond, excepted: ErrDropped, dropped: 2},
+		{policy: OverflowBlock, timeout: time.Second, drain: 20 * time.Millisecond},
+		{policy: OverflowBlock, drain: 20 * time.Millisecond},
+	}
+	for _, c := range cases {
+		*overflowPolicy, *blockTimeout = c.policy, c.timeout
+		defaultStream = &Stream{
+			input:   make(chan *model.Event, 1),
+			done:    make(chan struct{}),
+			closing: make(chan struct{}),
+		}
+		defaultStream.input <- &model.Event{}
+		if c.drain > 0 {
+			go func(input <-chan *model.Event) {
+				time.Sleep(c.drain)
+				for i := 0; i < 3; i++ {
+					<-input
+				}
+			}(defaultStream.input)
+		}
 
-	if count != 1 {
-		t.Fatalf("excepted 1, got %d", count)
+		err := Process(&model.Event{Name: "a"}, &model.Event{Name: "b"})
+		if err != c.excepted {
+			t.Fatalf("%s/%v: excepted %v, got %v", c.policy, c.timeout, c.excepted, err)
+		}
+		if _, _, dropped := Stats(); dropped != c.dropped {
+			t.Fatalf("%s/%v: excepted %d dropped events, got %d", c.policy, c.timeout, c.dropped, dropped)
+		}
 	}
-	count = 0
 }
@@ -0,0 +1,41 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronSchedule(t *testing.T) {
+	from := time.Date(2018, 3, 1, 10, 30, 0, 0, time.UTC) // Thursday
+	cases := []struct {
+		spec     string
+		excepted time.Time
+	}{
+		{"*/15 * * * *", time.Date(2018, 3, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2018, 3, 2, 2, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2018, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2018, 3, 2, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 15 * 0", time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)},
+		{"5,10 10 1 3 *", time.Date(2019, 3, 1, 10, 5, 0, 0, time.UTC)},
+		{"0,*/15 * * * *", time.Date(2018, 3, 1, 10, 45, 0, 0, time.UTC)},
+		{"5,40/10 * * * *", time.Date(2018, 3, 1, 10, 40, 0, 0, time.UTC)},
+		{"20/25 10,12 * * *", time.Date(2018, 3, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 1,*/9 * * *", time.Date(2018, 3, 1, 18, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := ParseCron(c.spec)
+		if nil != err {
+			t.Fatalf("failed to parse %s: %v", c.spec, err)
+		}
+		if next := s.Next(from); !next.Equal(c.excepted) {
+			t.Fatalf("%s: excepted %v, got %v", c.spec, c.excepted, next)
+		}
+	}
+
+	for _, invalid := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		if _, err := ParseCron(invalid); nil == err {
+			t.Fatalf("excepted error parsing %s", invalid)
+		}
+	}
+}